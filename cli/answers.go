@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// File prompt answers are stored in and replayed from, disabled if empty
+var AnswersFile = "answers.json"
+
+// Stored prompt answers by table, field and source key
+//
+// The file is plain JSON so it can also be edited by hand:
+//
+//	{"bots": {"ClientID": {"63a1...": "8160..."}}}
+type answerStore struct {
+	mu      sync.Mutex
+	path    string
+	answers map[string]map[string]map[string]string
+}
+
+// Stored answers of this run, nil if AnswersFile is empty
+var answers *answerStore
+
+// Loads an answers file, a missing file is treated as empty
+func loadAnswers(path string) (*answerStore, error) {
+	s := &answerStore{path: path, answers: make(map[string]map[string]map[string]string)}
+
+	bytes, err := os.ReadFile(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(bytes, &s.answers); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// Returns the stored answer for a question, if any
+func (s *answerStore) get(table, field, key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	answer, ok := s.answers[table][field][key]
+
+	return answer, ok
+}
+
+// Stores an answer and saves the file
+func (s *answerStore) set(table, field, key, answer string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.answers[table] == nil {
+		s.answers[table] = make(map[string]map[string]string)
+	}
+
+	if s.answers[table][field] == nil {
+		s.answers[table][field] = make(map[string]string)
+	}
+
+	s.answers[table][field][key] = answer
+
+	return s.save()
+}
+
+// Deletes stored answers and saves the file, field and key are optional and delete all answers of a table or field if empty
+//
+// Returns the number of answers deleted
+func (s *answerStore) delete(table, field, key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int
+
+	for f, keys := range s.answers[table] {
+		if field != "" && f != field {
+			continue
+		}
+
+		for k := range keys {
+			if key != "" && k != key {
+				continue
+			}
+
+			delete(keys, k)
+			deleted++
+		}
+
+		if len(keys) == 0 {
+			delete(s.answers[table], f)
+		}
+	}
+
+	if len(s.answers[table]) == 0 {
+		delete(s.answers, table)
+	}
+
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	return deleted, s.save()
+}
+
+// Writes the answers to the file, must be called with the lock held
+func (s *answerStore) save() error {
+	bytes, err := json.MarshalIndent(s.answers, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so an interrupted run cannot leave a truncated file
+	tmp := s.path + ".tmp"
+
+	if err := os.WriteFile(tmp, bytes, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// Returns the stored answer for a question, if it can be replayed
+func replayAnswer(q Question) (string, bool) {
+	if answers == nil || q.Table == "" || q.Field == "" || q.Key == "" {
+		return "", false
+	}
+
+	return answers.get(q.Table, q.Field, q.Key)
+}
+
+// Stores the answer to a question so later runs replay it
+func recordAnswer(q Question, answer string) {
+	if answers == nil || q.Table == "" || q.Field == "" || q.Key == "" {
+		return
+	}
+
+	if err := answers.set(q.Table, q.Field, q.Key, answer); err != nil {
+		NotifyMsg("error", "Failed to save answer to "+answers.path+": "+err.Error())
+	}
+}
+
+// Deletes the stored answer for a field of a record, use this when a replayed answer turns out to be wrong so the question is asked again
+func ForgetAnswer(table, field, key string) {
+	if key == "" {
+		return
+	}
+
+	pending.forget(table, field, key)
+
+	if answers == nil {
+		return
+	}
+
+	if _, err := answers.delete(table, field, key); err != nil {
+		NotifyMsg("error", "Failed to save answers to "+answers.path+": "+err.Error())
+	}
+}
+
+// Returns the value of the source key of a record as a string, empty if the record has no such key
+func recordKey(source Source, record map[string]any, srcKey string) string {
+	value, ok := lookupPath(record, srcKey)
+
+	if !ok || value == nil {
+		return ""
+	}
+
+	if parsed, err := source.ExtParse(value); err == nil {
+		value = parsed
+	}
+
+	return fmt.Sprint(value)
+}
+
+// Runs the answers command: lists, sets or deletes stored answers
+//
+//	answers list [table [field]]
+//	answers set <table> <field> <key> <answer>
+//	answers delete <table> [field [key]]
+func runAnswers(args []string) error {
+	if AnswersFile == "" {
+		return errors.New("no answers file, set one with -answers")
+	}
+
+	s, err := loadAnswers(AnswersFile)
+
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		var table, field string
+
+		if len(args) > 1 {
+			table = args[1]
+		}
+
+		if len(args) > 2 {
+			field = args[2]
+		}
+
+		var count int
+
+		for _, t := range sortedKeys(s.answers) {
+			if table != "" && t != table {
+				continue
+			}
+
+			for _, f := range sortedKeys(s.answers[t]) {
+				if field != "" && f != field {
+					continue
+				}
+
+				for _, k := range sortedKeys(s.answers[t][f]) {
+					fmt.Printf("%s\t%s\t%s\t%s\n", t, f, k, s.answers[t][f][k])
+					count++
+				}
+			}
+		}
+
+		NotifyMsg("info", fmt.Sprintf("%d stored answers in %s", count, filepath.Clean(AnswersFile)))
+	case "set":
+		if len(args) != 5 {
+			return errors.New("usage: answers set <table> <field> <key> <answer>")
+		}
+
+		if err := s.set(args[1], args[2], args[3], args[4]); err != nil {
+			return err
+		}
+
+		NotifyMsg("info", "Stored answer for "+args[1]+"."+args[2]+" ("+args[3]+")")
+	case "delete":
+		if len(args) < 2 || len(args) > 4 {
+			return errors.New("usage: answers delete <table> [field [key]]")
+		}
+
+		args = append(args, "", "")
+
+		deleted, err := s.delete(args[1], args[2], args[3])
+
+		if err != nil {
+			return err
+		}
+
+		NotifyMsg("info", fmt.Sprintf("Deleted %d stored answers", deleted))
+	default:
+		return errors.New("unknown answers command " + args[0] + ", expected list, set or delete")
+	}
+
+	return nil
+}
+
+// Returns the keys of a map, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
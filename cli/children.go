@@ -0,0 +1,81 @@
+package cli
+
+import "reflect"
+
+// A table filled from an array field of each record of its parent table
+type ChildTable struct {
+	// Name of the child table
+	Name string
+	// Src key of the array field in the parent record
+	Field string
+	// Schema struct of the child table, elements which are not documents are stored under the src key “value“
+	Schema any
+	// Column of the parent table the child rows refer to, defaults to itag
+	ParentKey string
+	// Name of the foreign key column added to the child table, defaults to parent_id (when ParentKey is itag) or ParentKey
+	ForeignKey string
+	// Options of the child table, transforms and signals work the same as for any other table
+	Opts BackupOpts
+}
+
+func (c ChildTable) withDefaults() ChildTable {
+	if c.Name == "" || c.Field == "" || c.Schema == nil {
+		panic("ChildTable: Name, Field and Schema must be set")
+	}
+
+	if c.ParentKey == "" {
+		c.ParentKey = "itag"
+	}
+
+	if c.ForeignKey == "" {
+		if c.ParentKey == "itag" {
+			c.ForeignKey = "parent_id"
+		} else {
+			c.ForeignKey = c.ParentKey
+		}
+	}
+
+	return c
+}
+
+// Inserts the elements of the array field of a parent record into the child table
+func (c *childBackup) insertChildren(parent map[string]any, parentKey *string) {
+	if c.table.summary.Aborted {
+		return
+	}
+
+	value := parent[c.def.Field]
+
+	if value == nil {
+		return
+	}
+
+	if parsed, err := c.table.source.ExtParse(value); err == nil {
+		value = parsed
+	}
+
+	if !isList(value) {
+		NotifyMsg("warning", "Field "+c.def.Field+" of "+c.table.name+" parent is not a list, ignoring it")
+		return
+	}
+
+	list := reflect.ValueOf(value)
+
+	for i := 0; i < list.Len(); i++ {
+		elem := list.Index(i).Interface()
+
+		c.counter++
+
+		record, ok := elem.(map[string]any)
+
+		if !ok {
+			record = map[string]any{"value": elem}
+		}
+
+		err := c.table.insertRecord(record, c.counter, map[string]any{c.def.ForeignKey: parentKey})
+
+		if err != nil && c.table.handleRowError(record, c.counter, err) {
+			return
+		}
+	}
+}
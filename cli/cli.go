@@ -3,11 +3,11 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/vbauerster/mpb/v8"
@@ -28,11 +28,50 @@ type TransformRow struct {
 	CurrentRecord    map[string]any
 	CurrentValue     any
 	CurrentIteration int
+	// Looks up rows of other tables and source entities by key
+	Lookup *Lookup
+	// Table, struct field and source key (see BackupOpts.SourceKey) of the value, used to store prompt answers
+	Table string
+	Field string
+	Key   string
+
+	// Redacted in prompts, see sensitive.go
+	sensitiveKeys  map[string]bool
+	sensitiveField bool
+}
+
+// Asks a question about the current value through the prompt server, answers are stored and replayed by later runs
+//
+// Returns a signal (to be returned by the transform) if the question cannot be answered in non-interactive mode
+func (tr TransformRow) Prompt(message string) (string, error) {
+	return Prompt(Question{
+		Message:   message,
+		Table:     tr.Table,
+		Field:     tr.Field,
+		Iteration: tr.CurrentIteration,
+		Record:    redactRecord(tr.CurrentRecord, tr.sensitiveKeys),
+		Key:       tr.Key,
+		Sensitive: tr.sensitiveField,
+	})
+}
+
+// Deletes the stored answer to the question about the current value, use this if a replayed answer turns out to be wrong
+func (tr TransformRow) ForgetAnswer() {
+	ForgetAnswer(tr.Table, tr.Field, tr.Key)
 }
 
 // This should return the value for the specific row
 type TransformFunc func(TransformRow) any
 
+// Context of the row currently being backed up
+type Context struct {
+	Table     string
+	Iteration int
+	Record    map[string]any
+	Records   []map[string]any
+	Lookup    *Lookup
+}
+
 type BackupOpts struct {
 	Debug             bool
 	IgnoreFKError     bool
@@ -40,6 +79,29 @@ type BackupOpts struct {
 	RenameTo          string
 	IndexCols         []string
 	Transforms        map[string]TransformFunc
+	// How values are converted to their column type
+	Coerce CoerceOpts
+	// What to do if a value cannot be converted to its column type
+	OnCoerceError ErrorPolicy
+	// Table comment, set using COMMENT ON TABLE
+	Comment string
+	// Called on each source record before its fields are mapped (and before field transforms run)
+	//
+	// Can return the same signals as transforms (ErrSkipRow etc.)
+	RowTransform func(record map[string]any) (map[string]any, error)
+	// Rows for which this returns false are dropped, called after joins and before RowTransform
+	Filter func(record map[string]any) bool
+	// Tables filled from array fields of each record, see ChildTable
+	Children []ChildTable
+	// Secondary source entities joined into each record, see Join
+	Joins []Join
+	// Src keys read by transforms or RowTransform but by no src tag, these are not reported as drift
+	KnownKeys []string
+	// Src key identifying a source record, used to store prompt answers (defaults to _id)
+	SourceKey string
+
+	// Internal hook called on each record before its fields are mapped
+	rowHook func(record map[string]any, c Context) (map[string]any, error)
 }
 
 type Source interface {
@@ -52,7 +114,10 @@ type Source interface {
 }
 
 func getTag(field reflect.StructField) (dest []string, src []string) {
-	if v, ok := tagCache[field.Name]; ok {
+	// Different schemas (e.g. child tables) may have fields with the same name
+	cacheKey := field.Name + "|" + field.Type.String() + "|" + string(field.Tag)
+
+	if v, ok := tagCache[cacheKey]; ok {
 		return v[0], v[1]
 	}
 
@@ -112,11 +177,23 @@ func getTag(field reflect.StructField) (dest []string, src []string) {
 		fieldType = "bigint"
 	}
 
+	// Handle floats
+	if fieldType == "float32" {
+		fieldType = "real"
+	} else if fieldType == "float64" {
+		fieldType = "double precision"
+	}
+
 	// Time is timestamptz
 	if fieldType == "Time" {
 		fieldType = "timestamptz"
 	}
 
+	// Durations are intervals
+	if fieldType == "Duration" {
+		fieldType = "interval"
+	}
+
 	if field.Type.Kind() == reflect.Slice || field.Tag.Get("tolist") == "true" {
 		fieldType += "[]"
 	}
@@ -129,7 +206,7 @@ func getTag(field reflect.StructField) (dest []string, src []string) {
 		fieldType = field.Tag.Get("mark")
 	}
 
-	tagCache[field.Name] = [2][]string{{destKeyName[0], fieldType + " " + cond}, {tagSplit[0], fieldType + " " + cond}}
+	tagCache[cacheKey] = [2][]string{{destKeyName[0], fieldType + " " + cond}, {tagSplit[0], fieldType + " " + cond}}
 
 	return []string{destKeyName[0], fieldType + " " + cond}, []string{tagSplit[0], fieldType + " " + cond}
 }
@@ -146,41 +223,158 @@ func resolveInput(input string) any {
 	return input
 }
 
+// Returns true if the field has either a literal or a SQL expression default
+func hasDefault(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup("default")
+	return ok || field.Tag.Get("defaultsql") != ""
+}
+
+// Returns the DEFAULT clause of a column
+//
+// “default“ is a literal of the column type (no SQL quoting needed) while “defaultsql“ is a raw SQL expression
+func defaultClause(field reflect.StructField) string {
+	literal, hasLiteral := field.Tag.Lookup("default")
+	expr := field.Tag.Get("defaultsql")
+
+	if hasLiteral && expr != "" {
+		panic("Field " + field.Name + " has both a default and a defaultsql tag")
+	}
+
+	if expr != "" {
+		return " DEFAULT " + expr
+	}
+
+	if !hasLiteral || literal == "SKIP" {
+		return ""
+	}
+
+	if literal == "null" {
+		return " DEFAULT NULL"
+	}
+
+	if strings.HasSuffix(literal, ")") {
+		NotifyMsg("warning", "Default of field "+field.Name+" looks like a SQL expression, use defaultsql if this is intended: "+literal)
+	}
+
+	return " DEFAULT " + quoteLiteral(literal)
+}
+
 func BackupTool(source Source, schemaName string, schema any, opts BackupOpts) {
 	if Bar == nil {
 		mb = mpb.New(mpb.WithWidth(64))
 	}
 
-	if opts.Transforms == nil {
-		opts.Transforms = make(map[string]TransformFunc)
-	}
-
 	tagCache = make(map[string][2][]string)
 
+	lookups.source = source
+
+	structType := reflect.TypeOf(schema)
+
+	// Register before checking the backup list, so placeholder rows can be created in tables not backed up in this run
+	registerTable(source, schemaName, structType, opts)
+
 	if len(backupList) != 0 && !slices.Contains(backupList, schemaName) {
 		NotifyMsg("info", "Skipping backup of "+schemaName)
 		return
 	}
 
+	if driftExceeded() {
+		NotifyMsg("warning", "Skipping backup of "+schemaName+" as the drift threshold was exceeded")
+		return
+	}
+
 	var err error
 	var cerr error
+	var data []map[string]any
+	var t *backupTable
 
-	if len(backupList) != 0 {
-		// Try deleting but ignore if delete fails
-		_, err = Pool.Exec(ctx, "DROP TABLE "+schemaName)
+	if !*OnlySchema {
+		data, err = source.GetRecords(schemaName)
 
 		if err != nil {
-			NotifyMsg("error", "Failed to drop table "+schemaName+": "+err.Error())
+			panic(err)
 		}
+
+		t = newBackupTable(source, schemaName, structType, opts, data)
+
+		// Drift is checked before the table is dropped or written to, so a run over the threshold stops early
+		if !t.scanDrift() {
+			return
+		}
+	}
+
+	if len(backupList) != 0 {
+		dropTable(schemaName, opts)
+	}
+
+	createTable(schemaName, structType, opts)
+
+	// If only schema, exit here
+	if *OnlySchema {
+		return
 	}
 
-	_, pgerr := Pool.Exec(ctx, "CREATE TABLE "+schemaName+" (itag UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4())")
+	count, cerr := source.GetCount(schemaName)
+
+	if cerr != nil {
+		panic(cerr)
+	}
+
+	var counter int
+
+	registry[schemaName].table = t
+
+	StartBar(schemaName, count, true)
+
+	NotifyMsg("info", "...")
+
+	for _, result := range data {
+		if counter == 0 {
+			NotifyMsg("info", "Backing up "+schemaName)
+		}
+
+		counter++
+
+		Bar.Increment()
+
+		err := t.insertRecord(result, counter, nil)
+
+		if err != nil && t.handleRowError(result, counter, err) {
+			break
+		}
+	}
+
+	t.finish()
+}
+
+// Drops a table and its child tables, ignoring any errors
+func dropTable(schemaName string, opts BackupOpts) {
+	for _, child := range opts.Children {
+		dropTable(child.Name, child.Opts)
+	}
+
+	_, err := Pool.Exec(ctx, "DROP TABLE "+schemaName)
+
+	if err != nil {
+		NotifyMsg("error", "Failed to drop table "+schemaName+": "+err.Error())
+	}
+}
+
+// Creates a table (and its child tables) from a schema struct
+func createTable(schemaName string, structType reflect.Type, opts BackupOpts) {
+	pgerr := execDDL("CREATE TABLE " + schemaName + " (itag UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4())")
 
 	if pgerr != nil {
 		panic(pgerr)
 	}
 
-	structType := reflect.TypeOf(schema)
+	if opts.Comment != "" {
+		pgerr = execDDL("COMMENT ON TABLE " + schemaName + " IS " + quoteLiteral(opts.Comment))
+
+		if pgerr != nil {
+			panic(pgerr)
+		}
+	}
 
 	// Schema generation
 	for _, field := range reflect.VisibleFields(structType) {
@@ -197,31 +391,23 @@ func BackupTool(source Source, schemaName string, schema any, opts BackupOpts) {
 			uniqueVal = " UNIQUE "
 		}
 
-		if field.Tag.Get("default") != "" {
-			defaultVal = field.Tag.Get("default")
-
-			if strings.HasPrefix(defaultVal, "{}") {
-				defaultVal = "'" + defaultVal + "'"
-			}
-
-			if strings.Contains(defaultVal, "uuid_generate_v4()") {
-				defaultVal = "uuid_generate_v4()"
-			}
-
-			if defaultVal == "SKIP" {
-				defaultVal = ""
-			} else {
-				defaultVal = " DEFAULT " + defaultVal
-			}
-		}
+		defaultVal = defaultClause(field)
 
 		// Create column
-		_, err := Pool.Exec(ctx, "ALTER TABLE "+schemaName+" ADD COLUMN "+tag[0]+" "+strings.Join(tag[1:], " ")+uniqueVal+defaultVal)
+		err := execDDL("ALTER TABLE " + schemaName + " ADD COLUMN " + tag[0] + " " + strings.Join(tag[1:], " ") + uniqueVal + defaultVal)
 		if err != nil {
 			NotifyMsg("error", "ALTER TABLE "+schemaName+" ADD COLUMN "+tag[0]+" "+strings.Join(tag[1:], " ")+uniqueVal+defaultVal)
 			panic(err)
 		}
 
+		if field.Tag.Get("comment") != "" {
+			err = execDDL("COMMENT ON COLUMN " + schemaName + "." + tag[0] + " IS " + quoteLiteral(field.Tag.Get("comment")))
+
+			if err != nil {
+				panic(err)
+			}
+		}
+
 		// Check for fkey, if so add it
 		if field.Tag.Get("fkey") != "" {
 			// Format for fkey is REFER_TABLE_NAME,COLUMN_NAME
@@ -229,7 +415,7 @@ func BackupTool(source Source, schemaName string, schema any, opts BackupOpts) {
 			fkeyRefersParentTable := fkeySplit[0]
 			fkeyRefersParentColumn := fkeySplit[1]
 
-			_, err := Pool.Exec(ctx, "ALTER TABLE "+schemaName+" ADD CONSTRAINT "+tag[0]+"_fkey FOREIGN KEY ("+tag[0]+") REFERENCES "+fkeyRefersParentTable+"("+fkeyRefersParentColumn+") ON DELETE CASCADE ON UPDATE CASCADE")
+			err := execDDL("ALTER TABLE " + schemaName + " ADD CONSTRAINT " + tag[0] + "_fkey FOREIGN KEY (" + tag[0] + ") REFERENCES " + fkeyRefersParentTable + "(" + fkeyRefersParentColumn + ") ON DELETE CASCADE ON UPDATE CASCADE")
 
 			if err != nil {
 				panic(err)
@@ -243,219 +429,478 @@ func BackupTool(source Source, schemaName string, schema any, opts BackupOpts) {
 		indexName := schemaName + "_migindex"
 		sqlStr := "CREATE INDEX " + indexName + " ON " + schemaName + "(" + colList + ")"
 
-		_, pgerr = Pool.Exec(ctx, sqlStr)
+		pgerr = execDDL(sqlStr)
 
 		if pgerr != nil {
 			panic(pgerr)
 		}
 	}
 
-	// If only schema, exit here
-	if *OnlySchema {
-		return
+	for _, child := range opts.Children {
+		child = child.withDefaults()
+
+		createTable(child.Name, reflect.TypeOf(child.Schema), child.Opts)
+
+		// Wire the child table to its parent
+		fkeyType := "uuid"
+
+		if child.ParentKey != "itag" {
+			fkeyType = parentKeyType(structType, child.ParentKey)
+		}
+
+		err := execDDL("ALTER TABLE " + child.Name + " ADD COLUMN " + child.ForeignKey + " " + fkeyType + " not null")
+
+		if err != nil {
+			panic(err)
+		}
+
+		err = execDDL("ALTER TABLE " + child.Name + " ADD CONSTRAINT " + child.ForeignKey + "_fkey FOREIGN KEY (" + child.ForeignKey + ") REFERENCES " + schemaName + "(" + child.ParentKey + ") ON DELETE CASCADE ON UPDATE CASCADE")
+
+		if err != nil {
+			panic(err)
+		}
 	}
+}
 
-	data, err := source.GetRecords(schemaName)
+// Returns the column type of the column named dest in a schema struct
+func parentKeyType(structType reflect.Type, dest string) string {
+	for _, field := range reflect.VisibleFields(structType) {
+		tag, _ := getTag(field)
 
-	if err != nil {
-		panic(err)
+		if tag[0] == dest {
+			return colType(tag)
+		}
 	}
 
-	count, cerr := source.GetCount(schemaName)
+	panic("Parent key " + dest + " not found in " + structType.Name())
+}
 
-	if cerr != nil {
-		panic(cerr)
+// A table that is being backed up
+type backupTable struct {
+	name       string
+	source     Source
+	structType reflect.Type
+	opts       BackupOpts
+	data       []map[string]any
+	summary    *TableSummary
+	children   []*childBackup
+	// Placeholder rows are not filtered, and null values without a default are inserted instead of prompting for them
+	placeholder bool
+
+	// Drift tracking, see drift.go
+	known map[string]bool
+	drift map[string]*driftKey
+	rows  int
+	// Drift of all source records was tracked before inserting them
+	driftScanned bool
+
+	// Src keys of sensitive fields, see sensitive.go
+	sensitive map[string]bool
+}
+
+type childBackup struct {
+	def     ChildTable
+	table   *backupTable
+	counter int
+}
+
+func newBackupTable(source Source, name string, structType reflect.Type, opts BackupOpts, data []map[string]any) *backupTable {
+	if opts.Transforms == nil {
+		opts.Transforms = make(map[string]TransformFunc)
 	}
 
-	var counter int
+	opts.Coerce.layoutStats = make(map[string]int)
 
-	StartBar(schemaName, count, true)
+	if opts.SourceKey == "" {
+		opts.SourceKey = "_id"
+	}
 
-	NotifyMsg("info", "...")
+	t := &backupTable{
+		name:       name,
+		source:     source,
+		structType: structType,
+		opts:       opts,
+		data:       data,
+		summary:    &TableSummary{Table: name},
+	}
 
-	for _, result := range data {
-		if counter == 0 {
-			NotifyMsg("info", "Backing up "+schemaName)
+	summaries = append(summaries, t.summary)
+
+	for _, child := range opts.Children {
+		child = child.withDefaults()
+
+		t.children = append(t.children, &childBackup{
+			def:   child,
+			table: newBackupTable(source, child.Name, reflect.TypeOf(child.Schema), child.Opts, nil),
+		})
+	}
+
+	return t
+}
+
+// Prints the reports of the table and its children and renames them if needed
+func (t *backupTable) finish() {
+	t.summary.print()
+
+	t.reportDrift()
+
+	reportLayouts(t.name, t.opts.Coerce.layoutStats)
+
+	for _, child := range t.children {
+		child.table.finish()
+	}
+
+	if t.opts.RenameTo != "" {
+		// Rename postgres table
+		sqlStr := "ALTER TABLE " + t.name + " RENAME TO " + t.opts.RenameTo
+		pgerr := execDDL(sqlStr)
+
+		if pgerr != nil {
+			panic(pgerr)
 		}
 
-		counter++
+		lookups.renameTable(t.name, t.opts.RenameTo)
+	}
+}
 
-		Bar.Increment()
+// Maps a source record to the table columns and inserts it, extra holds additional (already converted) column values
+//
+// Returns a signal (ErrSkipRow, ErrAbortTable or a RowError) if the row was not inserted
+func (t *backupTable) insertRecord(result map[string]any, counter int, extra map[string]any) error {
+	var err error
+	var pgerr error
 
-		var sqlStr string = "INSERT INTO " + schemaName + " ("
+	opts := t.opts
 
-		for _, field := range reflect.VisibleFields(structType) {
-			if field.Tag.Get("omit") == "true" {
-				continue
-			}
-			tag, _ := getTag(field) // dest tag here again
+	if !t.placeholder && !t.driftScanned {
+		t.trackDrift(result)
+	}
+
+	if len(opts.Joins) > 0 || opts.RowTransform != nil {
+		// Copy the record so the source records are left untouched
+		record := make(map[string]any, len(result))
+
+		for k, v := range result {
+			record[k] = v
+		}
+
+		result = record
+	}
 
-			sqlStr += tag[0] + ","
+	t.applyJoins(result)
+
+	if opts.Filter != nil && !t.placeholder && !opts.Filter(result) {
+		return errFiltered
+	}
+
+	if opts.RowTransform != nil {
+		result, err = opts.RowTransform(result)
+
+		if err != nil {
+			return err
 		}
+	}
 
-		sqlStr = sqlStr[:len(sqlStr)-1] + ") VALUES ("
+	if opts.rowHook != nil {
+		result, err = opts.rowHook(result, Context{
+			Table:     t.name,
+			Iteration: counter,
+			Record:    result,
+			Records:   t.data,
+			Lookup:    lookups,
+		})
 
-		args := make([]any, 0)
+		if err != nil {
+			return err
+		}
+	}
 
-		argNums := []string{}
+	// Identifies the record in stored prompt answers and logs
+	key := recordKey(t.source, result, opts.SourceKey)
 
-		var i int
+	rowLog := With(Fields{"table": t.name, "iteration": counter, "key": key})
 
-		var skipped bool
+	var sqlStr string = "INSERT INTO " + t.name + " ("
 
-		for _, field := range reflect.VisibleFields(structType) {
-			if field.Tag.Get("omit") == "true" {
-				continue
-			}
+	args := make([]any, 0)
 
-			tag, btag := getTag(field) // Here we need both
-			if opts.Debug {
-				NotifyMsg("debug", "Table:"+schemaName+"\nField:"+field.Name+"\nType:"+tag[1]+"\n")
-			}
+	// Whether each arg is sensitive, to redact args when logging them
+	sensitiveArgs := make([]bool, 0)
 
-			var res any
+	argNums := []string{}
 
-			res = result[btag[0]]
+	// Inserted values by column, for lookups
+	row := make(map[string]any)
 
-			if res == "" {
-				res = nil
-			}
+	var i int
 
-			if field.Tag.Get("defaultfunc") != "" || field.Tag.Get("pre") != "" || field.Tag.Get("tolist") != "" {
-				panic("defaultfunc and pre are deprecated, use a transform instead")
-			}
+	// Extra columns (such as the foreign key of child rows) come first, so their placeholders match their columns
+	for col, value := range extra {
+		sqlStr += col + ","
+		row[col] = value
+		args = append(args, value)
+		sensitiveArgs = append(sensitiveArgs, sensitiveName(col))
+		argNums = append(argNums, "$"+strconv.Itoa(i+1))
+		i++
+	}
+
+	for _, field := range reflect.VisibleFields(t.structType) {
+		if field.Tag.Get("omit") == "true" {
+			continue
+		}
+		tag, _ := getTag(field) // dest tag here again
+
+		sqlStr += tag[0] + ","
+	}
+
+	sqlStr = sqlStr[:len(sqlStr)-1] + ") VALUES ("
+
+	for _, field := range reflect.VisibleFields(t.structType) {
+		if field.Tag.Get("omit") == "true" {
+			continue
+		}
+
+		tag, btag := getTag(field) // Here we need both
+		sensitive := isSensitiveField(field)
+
+		if opts.Debug {
+			rowLog.With(Fields{"field": field.Name, "column": tag[0], "type": tag[1]}).Debug("Mapping field")
+		}
+
+		var res any
+
+		res, _ = lookupPath(result, btag[0])
 
-			// Apply transforms
-			if transform, ok := opts.Transforms[field.Name]; ok {
-				res = transform(TransformRow{
-					Records:          data,
-					CurrentRecord:    result,
-					CurrentValue:     res,
-					CurrentIteration: counter,
-				})
+		if res == "" {
+			res = nil
+		}
+
+		if field.Tag.Get("defaultfunc") != "" || field.Tag.Get("pre") != "" || field.Tag.Get("tolist") != "" {
+			panic("defaultfunc and pre are deprecated, use a transform instead")
+		}
+
+		// Apply transforms
+		if transform, ok := opts.Transforms[field.Name]; ok {
+			res = transform(TransformRow{
+				Records:          t.data,
+				CurrentRecord:    result,
+				CurrentValue:     res,
+				CurrentIteration: counter,
+				Lookup:           lookups,
+				Table:            t.name,
+				Field:            field.Name,
+				Key:              key,
+				sensitiveKeys:    t.sensitiveKeys(),
+				sensitiveField:   sensitive,
+			})
+
+			// Transforms can return a signal to skip or fail the row or abort the table
+			if signal, ok := res.(error); ok {
+				return signal
 			}
+		}
 
-			// Check again here
-			if res == "" {
-				res = nil
+		// Check again here
+		if res == "" {
+			res = nil
+		}
+
+		if res == nil {
+			if field.Tag.Get("default") == "SKIP" {
+				// Skip the row due to its default value
+				return ErrSkipRow
 			}
 
-			if res == nil {
-				if field.Tag.Get("default") != "" {
-					if strings.Contains(field.Tag.Get("default"), "SKIP") {
-						NotifyMsg("warning", "Skipping row due to default value at iteration "+strconv.Itoa(counter))
-						skipped = true
-						continue
-					}
-
-					res = resolveInput(field.Tag.Get("default"))
-					if resStr, ok := res.(string); ok {
-						resStr = strings.TrimPrefix(resStr, "'")
-						resStr = strings.TrimSuffix(resStr, "'")
-
-						res = resStr
-					}
-				} else {
-					// Ask user what to do
-					var msg = PromptServerChannel("What should the value of " + tag[0] + " be? (currently null)")
-
-					res = resolveInput(msg)
+			if hasDefault(field) {
+				// Let postgres fill in the default
+				if field.Tag.Get("log") == "1" {
+					rowLog.With(Fields{"field": field.Name, "src": btag[0], "column": tag[0]}).Debug("Setting to DEFAULT")
 				}
+
+				argNums = append(argNums, "DEFAULT")
+				continue
 			}
 
-			if skipped {
-				break
+			if t.placeholder {
+				row[tag[0]] = nil
+				args = append(args, nil)
+				sensitiveArgs = append(sensitiveArgs, sensitive)
+				argNums = append(argNums, "$"+strconv.Itoa(i+1))
+				i++
+				continue
 			}
 
-			if field.Tag.Get("log") == "1" {
-				fmt.Println("Setting", btag[0], "(", tag[0], ") to", res)
+			// Ask user what to do
+			msg, err := Prompt(Question{
+				Message:   "What should the value of " + tag[0] + " be? (currently null)",
+				Table:     t.name,
+				Field:     field.Name,
+				Column:    tag[0],
+				Iteration: counter,
+				Record:    redactRecord(normalizeRecord(t.source, result), t.sensitiveKeys()),
+				Type:      colType(tag),
+				Key:       key,
+				Sensitive: sensitive,
+			})
+
+			if err != nil {
+				return err
 			}
 
-			// Handle mark of timestamptz
-			if strings.HasPrefix(tag[1], "time") {
-				// check if res is int64
-				if opts.Debug {
-					NotifyMsg("debug", "Converting a "+reflect.TypeOf(res).Name()+" to time.Time")
-				}
+			res = parseAnswer(msg, colType(tag))
+		}
 
-				if resCast, ok := res.(int64); ok {
-					res = time.UnixMilli(resCast)
-				} else if resCast, ok := res.(float64); ok {
-					res = time.UnixMilli(int64(resCast))
-				} else if resCast, ok := res.(string); ok {
-					// Cast string to int64
-					resD, err := strconv.ParseInt(resCast, 10, 64)
-					if err != nil {
-						// Could be a datetime string
-						resDV, err := time.Parse(time.RFC3339, resCast)
-						if err != nil {
-							// Last ditch effort, try checking if its NOW or something
-							if strings.Contains(resCast, "NOW") {
-								res = time.Now()
-							} else {
-								panic(err)
-							}
-						} else {
-							res = resDV
-						}
-					} else {
-						res = time.UnixMilli(resD)
-					}
-				}
+		if field.Tag.Get("log") == "1" {
+			rowLog.With(Fields{"field": field.Name, "src": btag[0], "column": tag[0], "value": fmt.Sprint(redactArg(sensitive, res))}).Debug("Setting value")
+		}
+
+		result, err := t.source.ExtParse(res)
+
+		if err == nil {
+			res = result
+		}
+
+		res, err = Coerce(colType(tag), res, fieldCoerceOpts(field, opts.Coerce))
+
+		if err != nil {
+			if sensitive {
+				err = redactError(err)
 			}
 
-			result, err := source.ExtParse(res)
+			rowLog.With(Fields{"field": field.Name, "column": tag[0]}).Warning(err.Error())
 
-			if err == nil {
-				res = result
+			switch opts.OnCoerceError {
+			case SkipRowOnError:
+				return fmt.Errorf("%w: %v", ErrSkipRow, err)
+			case NullOnError:
+				res = nil
+			default:
+				panic(err)
 			}
 
-			args = append(args, res)
+			if hasDefault(field) {
+				argNums = append(argNums, "DEFAULT")
+				continue
+			}
+		}
 
-			argNums = append(argNums, "$"+strconv.Itoa(i+1))
+		res, err = t.applyFKPolicy(field, res, counter)
 
-			i++
+		if err != nil {
+			return err
 		}
 
-		if skipped {
+		if res == nil && hasDefault(field) {
+			argNums = append(argNums, "DEFAULT")
 			continue
 		}
 
-		sqlStr += strings.Join(argNums, ",") + ")"
+		row[tag[0]] = res
+		args = append(args, res)
+		sensitiveArgs = append(sensitiveArgs, sensitive)
 
-		if opts.Debug {
-			NotifyMsg("debug", "SQL String: "+sqlStr)
+		argNums = append(argNums, "$"+strconv.Itoa(i+1))
+
+		i++
+	}
+
+	sqlStr += strings.Join(argNums, ",") + ")"
+
+	if opts.Debug {
+		rowLog.With(Fields{"sql": sqlStr}).Debug("Inserting row")
+	}
+
+	// Child tables need the key of the inserted row
+	parentKeys := t.parentKeys()
+	returned := make([]*string, len(parentKeys))
+
+	if len(parentKeys) > 0 {
+		dest := make([]any, len(parentKeys))
+
+		for i := range returned {
+			dest[i] = &returned[i]
 		}
 
+		pgerr = Pool.QueryRow(ctx, sqlStr+" RETURNING "+strings.Join(parentKeys, "::text, ")+"::text", args...).Scan(dest...)
+	} else {
 		_, pgerr = Pool.Exec(ctx, sqlStr, args...)
+	}
 
-		if pgerr != nil {
-			if opts.IgnoreFKError && strings.Contains(pgerr.Error(), "violates foreign key") {
-				NotifyMsg("warning", "Ignoring foreign key error on iter "+strconv.Itoa(counter)+": "+pgerr.Error())
-				continue
-			} else if opts.IgnoreUniqueError && strings.Contains(pgerr.Error(), "unique constraint") {
-				NotifyMsg("warning", "Ignoring unique error on iter "+strconv.Itoa(counter)+": "+pgerr.Error())
-				continue
-			}
-			NotifyMsg("error", "Error on iter "+strconv.Itoa(counter)+": "+pgerr.Error())
-			NotifyMsg("error", "Failing SQL: "+sqlStr+"\nArgs: "+fmt.Sprint(args))
-			fmt.Println("Failing SQL: ", sqlStr, args)
-			for _, arg := range args {
-				if arg != nil {
-					fmt.Println(reflect.TypeOf(arg), arg)
-				}
+	if pgerr != nil {
+		if opts.IgnoreFKError && strings.Contains(pgerr.Error(), "violates foreign key") {
+			rowLog.Warning("Ignoring foreign key error: " + pgerr.Error())
+			t.summary.Ignored++
+			return nil
+		} else if opts.IgnoreUniqueError && strings.Contains(pgerr.Error(), "unique constraint") {
+			rowLog.Warning("Ignoring unique error: " + pgerr.Error())
+			t.summary.Ignored++
+			return nil
+		} else if t.placeholder {
+			return pgerr
+		}
+		logArgs := make([]any, len(args))
+		argTypes := make([]string, len(args))
+
+		for i, arg := range args {
+			logArgs[i] = redactArg(sensitiveArgs[i], arg)
+			argTypes[i] = fmt.Sprintf("%T", arg)
+		}
+
+		rowLog.With(Fields{"sql": sqlStr, "args": fmt.Sprint(logArgs), "arg_types": strings.Join(argTypes, ", ")}).Error(pgerr.Error())
+		panic(pgerr)
+	}
+
+	t.summary.Inserted++
+
+	lookups.Record(t.name, row)
+
+	for _, child := range t.children {
+		for i, key := range parentKeys {
+			if key == child.def.ParentKey {
+				child.insertChildren(result, returned[i])
 			}
-			panic(pgerr)
 		}
 	}
 
-	if opts.RenameTo != "" {
-		// Rename postgres table
-		sqlStr := "ALTER TABLE " + schemaName + " RENAME TO " + opts.RenameTo
-		_, pgerr = Pool.Exec(ctx, sqlStr)
+	return nil
+}
 
-		if pgerr != nil {
-			panic(pgerr)
+// Returns the parent columns child tables refer to
+func (t *backupTable) parentKeys() []string {
+	var keys []string
+
+	for _, child := range t.children {
+		if !slices.Contains(keys, child.def.ParentKey) {
+			keys = append(keys, child.def.ParentKey)
+		}
+	}
+
+	return keys
+}
+
+// Handles a row that was not inserted, returns true if the table should be aborted
+func (t *backupTable) handleRowError(record map[string]any, counter int, err error) bool {
+	rowLog := With(Fields{"table": t.name, "iteration": counter, "key": recordKey(t.source, record, t.opts.SourceKey)})
+
+	switch {
+	case errors.Is(err, errFiltered):
+		t.summary.Filtered++
+	case errors.Is(err, ErrSkipRow):
+		if err == ErrSkipRow {
+			rowLog.Warning("Skipping row")
+		} else {
+			rowLog.Warning("Skipping row: " + err.Error())
 		}
+		t.summary.Skipped++
+	case errors.Is(err, ErrAbortTable):
+		rowLog.Error("Aborting backup of " + t.name + ": " + err.Error())
+		t.summary.Aborted = true
+		return true
+	default:
+		rowLog.Warning("Row failed: " + err.Error())
+		t.summary.Failed++
+		writeDeadLetter(t.name, counter, redactRecord(record, t.sensitiveKeys()), err)
 	}
+
+	return false
 }
@@ -0,0 +1,411 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// What to do when a value could not be coerced to its column type
+type ErrorPolicy int
+
+const (
+	// Abort the whole run (the default)
+	AbortOnError ErrorPolicy = iota
+	// Skip the row
+	SkipRowOnError
+	// Insert a null (or the column default if there is one) instead
+	NullOnError
+)
+
+type CoerceOpts struct {
+	// In strict mode, lossy or guessed conversions (such as 1.5 -> integer or "yes" -> boolean) are errors
+	Strict bool
+	// Layouts to try (in order) when parsing time strings, defaults to TimeLayouts
+	TimeLayouts []string
+	// Timezone assumed for timestamps without one, defaults to UTC
+	Location *time.Location
+	// Unit of unix epochs (s, ms, us or ns), guessed from the size of the number if empty
+	EpochUnit string
+
+	// Number of times each layout (or epoch unit) was used to parse a time
+	layoutStats map[string]int
+}
+
+type CoerceError struct {
+	Type  string
+	Value any
+	Err   error
+	// Hides the value in the message, set for sensitive fields
+	Redacted bool
+}
+
+func (e *CoerceError) Error() string {
+	var value any = e.Value
+
+	if e.Redacted {
+		value = Redacted
+	}
+
+	msg := fmt.Sprintf("cannot coerce %T (%v) to %s", e.Value, value, e.Type)
+
+	if e.Err != nil {
+		reason := e.Err.Error()
+
+		if value := fmt.Sprint(e.Value); e.Redacted && value != "" {
+			// Parse errors quote the value
+			reason = strings.ReplaceAll(reason, value, Redacted)
+		}
+
+		msg += ": " + reason
+	}
+
+	return msg
+}
+
+func (e *CoerceError) Unwrap() error {
+	return e.Err
+}
+
+// Returns the column type of a tag without any constraints
+func colType(tag []string) string {
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(tag[1]), "not null"))
+}
+
+// Converts a value from a source to a value that can be inserted into a column of type colType
+func Coerce(colType string, v any, opts CoerceOpts) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil, nil
+		}
+
+		return Coerce(colType, rv.Elem().Interface(), opts)
+	case reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+
+	colType = strings.ToLower(colType)
+
+	if strings.HasSuffix(colType, "[]") {
+		return coerceArray(strings.TrimSuffix(colType, "[]"), v, opts)
+	}
+
+	var res any
+	var err error
+
+	switch colType {
+	case "text", "varchar", "citext", "uuid":
+		res, err = coerceText(v, opts)
+	case "smallint", "int2":
+		res, err = coerceInt(v, math.MinInt16, math.MaxInt16, opts)
+	case "integer", "int", "int4", "serial":
+		res, err = coerceInt(v, math.MinInt32, math.MaxInt32, opts)
+	case "bigint", "int8", "bigserial":
+		res, err = coerceInt(v, math.MinInt64, math.MaxInt64, opts)
+	case "real", "double precision", "float8", "float4":
+		res, err = coerceFloat(v, opts)
+	case "numeric", "decimal":
+		res, err = coerceNumeric(v, opts)
+	case "boolean", "bool":
+		res, err = coerceBool(v, opts)
+	case "timestamptz", "timestamp", "date":
+		res, err = coerceTime(v, opts)
+	case "interval":
+		res, err = coerceInterval(v, opts)
+	case "jsonb", "json":
+		res, err = coerceJSON(v, opts)
+	default:
+		// Unknown type, let postgres handle it
+		return v, nil
+	}
+
+	if err != nil {
+		return nil, &CoerceError{Type: colType, Value: v, Err: err}
+	}
+
+	return res, nil
+}
+
+func isList(v any) bool {
+	kind := reflect.TypeOf(v).Kind()
+
+	if kind == reflect.Slice {
+		// Byte slices are not lists
+		return reflect.TypeOf(v).Elem().Kind() != reflect.Uint8
+	}
+
+	return kind == reflect.Array
+}
+
+func coerceArray(elemType string, v any, opts CoerceOpts) (any, error) {
+	if !isList(v) {
+		if opts.Strict {
+			return nil, &CoerceError{Type: elemType + "[]", Value: v, Err: errors.New("not a list")}
+		}
+
+		// Scalar, so wrap it in a list
+		elem, err := Coerce(elemType, v, opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []any{elem}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	list := make([]any, rv.Len())
+
+	for i := 0; i < rv.Len(); i++ {
+		elem, err := Coerce(elemType, rv.Index(i).Interface(), opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		list[i] = elem
+	}
+
+	return list, nil
+}
+
+func coerceText(v any, opts CoerceOpts) (any, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case []byte:
+		return string(val), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return val.String(), nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+
+	if opts.Strict {
+		return nil, errors.New("not a string")
+	}
+
+	if rv.Kind() == reflect.Map || rv.Kind() == reflect.Slice {
+		if bytes, err := json.Marshal(v); err == nil {
+			return string(bytes), nil
+		}
+	}
+
+	return fmt.Sprint(v), nil
+}
+
+// Returns the value of a number as a float64 and whether it is a number at all
+func toFloat(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+
+	return 0, false
+}
+
+func coerceInt(v any, min, max int64, opts CoerceOpts) (any, error) {
+	var n int64
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, errors.New("out of range")
+		}
+
+		n = int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+
+		if f != math.Trunc(f) && opts.Strict {
+			return nil, errors.New("has a fractional part")
+		}
+
+		// float64(math.MaxInt64) rounds up to 2^63, which does not fit
+		if f < math.MinInt64 || f >= math.MaxInt64 || math.IsNaN(f) {
+			return nil, errors.New("out of range")
+		}
+
+		n = int64(f)
+	case reflect.String:
+		s := strings.TrimSpace(rv.String())
+
+		var err error
+		n, err = strconv.ParseInt(s, 10, 64)
+
+		if err != nil {
+			if opts.Strict {
+				return nil, err
+			}
+
+			f, ferr := strconv.ParseFloat(s, 64)
+
+			if ferr != nil {
+				return nil, err
+			}
+
+			return coerceInt(f, min, max, opts)
+		}
+	case reflect.Bool:
+		if opts.Strict {
+			return nil, errors.New("not a number")
+		}
+
+		if rv.Bool() {
+			n = 1
+		}
+	default:
+		return nil, errors.New("not a number")
+	}
+
+	if n < min || n > max {
+		return nil, errors.New("out of range")
+	}
+
+	return n, nil
+}
+
+func coerceFloat(v any, opts CoerceOpts) (any, error) {
+	if f, ok := toFloat(v); ok {
+		return f, nil
+	}
+
+	if s, ok := v.(string); ok {
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+
+	return nil, errors.New("not a number")
+}
+
+func coerceNumeric(v any, opts CoerceOpts) (any, error) {
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+
+		// Keep the string so no precision is lost
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, err
+		}
+
+		return s, nil
+	}
+
+	return coerceFloat(v, opts)
+}
+
+func coerceBool(v any, opts CoerceOpts) (any, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+
+	if s, ok := v.(string); ok {
+		s = strings.ToLower(strings.TrimSpace(s))
+
+		b, err := strconv.ParseBool(s)
+
+		if err == nil {
+			return b, nil
+		}
+
+		if !opts.Strict {
+			switch s {
+			case "yes", "y", "on":
+				return true, nil
+			case "no", "n", "off":
+				return false, nil
+			}
+		}
+
+		return nil, err
+	}
+
+	if f, ok := toFloat(v); ok {
+		if f == 0 || f == 1 || !opts.Strict {
+			return f != 0, nil
+		}
+	}
+
+	return nil, errors.New("not a boolean")
+}
+
+func coerceInterval(v any, opts CoerceOpts) (any, error) {
+	if d, ok := v.(time.Duration); ok {
+		return d, nil
+	}
+
+	// Plain numbers are assumed to be milliseconds
+	if f, ok := toFloat(v); ok {
+		return time.Duration(f * float64(time.Millisecond)), nil
+	}
+
+	s, ok := v.(string)
+
+	if !ok {
+		return nil, errors.New("not an interval")
+	}
+
+	if d, err := time.ParseDuration(strings.TrimSpace(s)); err == nil {
+		return d, nil
+	}
+
+	// Let postgres parse it (e.g. '12 hours')
+	return s, nil
+}
+
+func coerceJSON(v any, opts CoerceOpts) (any, error) {
+	if s, ok := v.(string); ok {
+		if json.Valid([]byte(s)) {
+			return s, nil
+		}
+
+		if opts.Strict {
+			return nil, errors.New("not valid json")
+		}
+
+		// Store it as a JSON string
+		return json.Marshal(s)
+	}
+
+	if _, err := json.Marshal(v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
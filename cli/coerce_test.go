@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCoerceIntBounds(t *testing.T) {
+	tests := []struct {
+		colType string
+		v       any
+		want    any
+	}{
+		{"bigint", float64(1 << 62), int64(1 << 62)},
+		{"bigint", float64(math.MinInt64), int64(math.MinInt64)},
+		{"bigint", float64(1 << 63), nil},
+		{"bigint", math.Nextafter(float64(1<<63), math.Inf(1)), nil},
+		{"bigint", "9223372036854775808", nil},
+		{"integer", float64(math.MaxInt32), int64(math.MaxInt32)},
+		{"integer", float64(math.MaxInt32 + 1), nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Coerce(tt.colType, tt.v, CoerceOpts{})
+
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("Coerce(%s, %v) = %v, want an error", tt.colType, tt.v, got)
+			}
+
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("Coerce(%s, %v) = %v, %v, want %v", tt.colType, tt.v, got, err, tt.want)
+		}
+	}
+}
@@ -2,45 +2,13 @@
 package cli
 
 import (
-	"fmt"
-	"io"
-	"net/http"
-
-	"github.com/fatih/color"
 	"github.com/vbauerster/mpb/v8"
 	"github.com/vbauerster/mpb/v8/decor"
 )
 
-var warningFunc = color.New(color.FgYellow).SprintFunc()
-var errorFunc = color.New(color.FgRed).SprintFunc()
-var debugFunc = color.New(color.FgHiCyan).SprintFunc()
-var infoFunc = color.New(color.FgHiGreen).SprintFunc()
-
 var mb *mpb.Progress
 var Bar *mpb.Bar
 
-func NotifyMsg(level string, msg string) {
-	if level == "warning" {
-		level = warningFunc(level)
-	} else if level == "error" {
-		level = errorFunc(level)
-	} else if level == "debug" {
-		level = debugFunc(level)
-	} else if level == "info" {
-		level = infoFunc(level)
-	} else {
-		panic("invalid log level")
-	}
-
-	if mb == nil {
-		fmt.Print(fmt.Sprintln(level+":", msg))
-		return
-	}
-
-	// Send message to daemon
-	mb.Write([]byte(fmt.Sprintln(level+":", msg)))
-}
-
 func StartBar(schemaName string, count int64, removeOld bool) (b *mpb.Bar) {
 	if Bar != nil && removeOld {
 		Bar.Abort(true)
@@ -81,48 +49,3 @@ func StartBar(schemaName string, count int64, removeOld bool) (b *mpb.Bar) {
 
 	return bar
 }
-
-func PromptServerChannel(message string) string {
-	NotifyMsg("info", "To continue, please send an input to the following question to http://localhost:34012/msg: "+message)
-	channel := make(chan string)
-
-	killChan := make(chan bool)
-
-	go func() {
-		r := http.NewServeMux()
-
-		srv := &http.Server{Addr: ":34012", Handler: r}
-
-		r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			w.Write([]byte(message))
-		})
-
-		r.HandleFunc("/msg", func(w http.ResponseWriter, r *http.Request) {
-			// Read body
-			body, err := io.ReadAll(r.Body)
-
-			if err != nil {
-				w.Write([]byte("Error reading body"))
-				return
-			}
-
-			channel <- string(body)
-		})
-
-		go srv.ListenAndServe()
-
-		<-killChan
-
-		NotifyMsg("info", "Closing server")
-
-		srv.Close()
-	}()
-
-	id := <-channel
-
-	NotifyMsg("info", "Received input: "+id)
-
-	killChan <- true
-
-	return id
-}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"os"
+	"strings"
+)
+
+var ddlLog []string
+
+// Runs a schema statement and records it for the DDL export
+func execDDL(sql string) error {
+	_, err := Pool.Exec(ctx, sql)
+
+	if err != nil {
+		return err
+	}
+
+	ddlLog = append(ddlLog, sql)
+	return nil
+}
+
+// Quotes a string as a postgres literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Writes all schema statements run so far to a file
+func WriteDDL(filename string) error {
+	var sb strings.Builder
+
+	for _, stmt := range ddlLog {
+		sb.WriteString(stmt)
+		sb.WriteString(";\n")
+	}
+
+	return os.WriteFile(filename, []byte(sb.String()), 0644)
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// File failed rows are written to (as JSON lines), disabled if empty
+var DeadLetterFile string
+
+type deadLetter struct {
+	Table     string         `json:"table"`
+	Iteration int            `json:"iteration"`
+	Reason    string         `json:"reason"`
+	Record    map[string]any `json:"record"`
+	Time      time.Time      `json:"time"`
+}
+
+func writeDeadLetter(table string, iteration int, record map[string]any, reason error) {
+	if DeadLetterFile == "" {
+		return
+	}
+
+	entry := deadLetter{
+		Table:     table,
+		Iteration: iteration,
+		Reason:    reason.Error(),
+		Record:    record,
+		Time:      time.Now(),
+	}
+
+	bytes, err := json.Marshal(entry)
+
+	if err != nil {
+		// Record has values json cannot handle, so store it as a string instead
+		entry.Record = map[string]any{"raw": fmt.Sprint(record)}
+		bytes, err = json.Marshal(entry)
+
+		if err != nil {
+			NotifyMsg("error", "Failed to encode dead letter: "+err.Error())
+			return
+		}
+	}
+
+	file, err := os.OpenFile(DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+	if err != nil {
+		NotifyMsg("error", "Failed to open dead letter file: "+err.Error())
+		return
+	}
+
+	defer file.Close()
+
+	file.Write(append(bytes, '\n'))
+}
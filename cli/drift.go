@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Source keys which are never reported as drift
+var DriftIgnoreKeys = []string{"_id"}
+
+// Maximum number of unmapped source keys (over all tables) before the run fails, disabled if negative
+var DriftThreshold = -1
+
+// Number of example values kept per unmapped key
+const driftExamples = 3
+
+// An unmapped source key of a table
+type driftKey struct {
+	Count    int
+	Examples []string
+}
+
+type driftEntry struct {
+	Table    string   `json:"table"`
+	Key      string   `json:"key"`
+	Count    int      `json:"count"`
+	Rows     int      `json:"rows"`
+	Examples []string `json:"examples"`
+}
+
+// Number of unmapped keys found in this run
+var driftTotal int
+
+// Returns the src keys of a table which are mapped by its schema struct, options or KnownKeys
+func (t *backupTable) knownKeys() map[string]bool {
+	known := make(map[string]bool)
+
+	for _, key := range DriftIgnoreKeys {
+		known[key] = true
+	}
+
+	for _, key := range t.opts.KnownKeys {
+		known[key] = true
+	}
+
+	for _, field := range reflect.VisibleFields(t.structType) {
+		_, btag := getTag(field)
+
+		// Nested keys (a.b) map the top level key
+		known[strings.Split(btag[0], ".")[0]] = true
+	}
+
+	for _, join := range t.opts.Joins {
+		known[join.LocalKey] = true
+	}
+
+	for _, child := range t.opts.Children {
+		known[child.Field] = true
+	}
+
+	return known
+}
+
+// Records the keys of a source record which are not mapped
+func (t *backupTable) trackDrift(record map[string]any) {
+	if t.known == nil {
+		t.known = t.knownKeys()
+		t.drift = make(map[string]*driftKey)
+	}
+
+	t.rows++
+
+	for key, value := range record {
+		if t.known[key] {
+			continue
+		}
+
+		d, ok := t.drift[key]
+
+		if !ok {
+			d = &driftKey{}
+			t.drift[key] = d
+		}
+
+		d.Count++
+
+		if len(d.Examples) < driftExamples && value != nil {
+			example := Redacted
+
+			if !sensitiveName(key) {
+				example = fmt.Sprint(redactValue(value, key, nil))
+			}
+
+			example = shorten(example, 80)
+
+			d.Examples = append(d.Examples, example)
+		}
+	}
+}
+
+// Returns s cut to at most n characters (not bytes, so multi-byte characters are kept whole), ending in "..." if cut
+func shorten(s string, n int) string {
+	runes := []rune(s)
+
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n-3]) + "..."
+}
+
+// Prints the unmapped keys of a table and adds them to the run report
+func (t *backupTable) reportDrift() {
+	if len(t.drift) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(t.drift))
+
+	for key := range t.drift {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	NotifyMsg("warning", "Drift in "+t.name+": "+strconv.Itoa(len(keys))+" source keys are not mapped by any field")
+
+	for _, key := range keys {
+		d := t.drift[key]
+
+		NotifyMsg("warning", "    "+key+": in "+strconv.Itoa(d.Count)+" of "+strconv.Itoa(t.rows)+" rows, e.g. "+strings.Join(d.Examples, " | "))
+
+		Report("drift", driftEntry{
+			Table:    t.name,
+			Key:      key,
+			Count:    d.Count,
+			Rows:     t.rows,
+			Examples: d.Examples,
+		})
+	}
+
+	driftTotal += len(keys)
+}
+
+// Tracks the drift of all source records of a table before any are inserted
+//
+// Returns false (after reporting the drift) if the unmapped keys found so far exceed DriftThreshold, so the table is
+// not backed up
+func (t *backupTable) scanDrift() bool {
+	for _, record := range t.data {
+		t.trackDrift(record)
+	}
+
+	t.driftScanned = true
+
+	if DriftThreshold < 0 || driftTotal+len(t.drift) <= DriftThreshold {
+		return true
+	}
+
+	t.reportDrift()
+
+	NotifyMsg("error", "Not backing up "+t.name+": "+checkDrift().Error())
+
+	return false
+}
+
+// Returns true if the unmapped keys reported so far exceed DriftThreshold
+func driftExceeded() bool {
+	return DriftThreshold >= 0 && driftTotal > DriftThreshold
+}
+
+// Returns an error if more keys are unmapped than DriftThreshold allows
+func checkDrift() error {
+	if !driftExceeded() {
+		return nil
+	}
+
+	return errors.New(strconv.Itoa(driftTotal) + " unmapped source keys found, more than the threshold of " + strconv.Itoa(DriftThreshold))
+}
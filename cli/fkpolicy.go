@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// What to do when the parent row of a foreign key (set using the fkpolicy tag) does not exist
+const (
+	// Create a placeholder parent row using the schema struct, defaults and transforms of the parent table
+	FKCreate = "create"
+	// Insert a null (or the column default if there is one) instead
+	FKNull = "null"
+)
+
+// A table registered with BackupTool in this run, placeholder rows are inserted through it
+type registeredTable struct {
+	source     Source
+	structType reflect.Type
+	opts       BackupOpts
+	table      *backupTable
+}
+
+// Tables passed to BackupTool in this run by name (and by the name they were renamed to)
+var registry = map[string]*registeredTable{}
+
+func registerTable(source Source, name string, structType reflect.Type, opts BackupOpts) {
+	reg := &registeredTable{source: source, structType: structType, opts: opts}
+
+	registry[name] = reg
+
+	if opts.RenameTo != "" {
+		registry[opts.RenameTo] = reg
+	}
+}
+
+// Returns the table rows of a registered table are inserted through
+func (r *registeredTable) backupTable(name string) *backupTable {
+	if r.table == nil {
+		// Table was not backed up in this run (e.g. not in the backup list), so only use it for placeholders
+		r.table = newBackupTable(r.source, name, r.structType, r.opts, nil)
+	}
+
+	return r.table
+}
+
+// A placeholder parent row created for a dangling foreign key
+type placeholderEntry struct {
+	Table        string    `json:"table"`
+	Column       string    `json:"column"`
+	Value        any       `json:"value"`
+	ReferencedBy string    `json:"referenced_by"`
+	Field        string    `json:"field"`
+	Iteration    int       `json:"iteration"`
+	Time         time.Time `json:"time"`
+}
+
+// Applies the fkpolicy of a field to a (converted) value, returning the value to insert
+func (t *backupTable) applyFKPolicy(field reflect.StructField, value any, counter int) (any, error) {
+	policy := field.Tag.Get("fkpolicy")
+
+	if policy == "" || value == nil {
+		return value, nil
+	}
+
+	fkey := strings.Split(field.Tag.Get("fkey"), ",")
+
+	if len(fkey) != 2 {
+		panic("fkpolicy set on field " + field.Name + " without a fkey")
+	}
+
+	if lookups.Exists(fkey[0], fkey[1], value) {
+		return value, nil
+	}
+
+	switch policy {
+	case FKNull:
+		With(Fields{"table": t.name, "field": field.Name, "iteration": counter}).Warning(fkey[0] + "." + fkey[1] + " = " + fmt.Sprint(value) + " not found, setting it to null")
+		return nil, nil
+	case FKCreate:
+		created, err := createPlaceholder(fkey[0], fkey[1], value)
+
+		if err != nil {
+			return nil, FailRow("could not create placeholder for " + fkey[0] + "." + fkey[1] + " = " + fmt.Sprint(value) + ": " + err.Error())
+		}
+
+		if !created {
+			// The insert was ignored (IgnoreUniqueError or IgnoreFKError of the parent table), so there is nothing to report
+			With(Fields{"table": t.name, "field": field.Name, "iteration": counter}).Warning("Placeholder row in " + fkey[0] + " for " + fkey[1] + " = " + fmt.Sprint(value) + " was not created, its insert was ignored")
+			return value, nil
+		}
+
+		With(Fields{"table": t.name, "field": field.Name, "iteration": counter}).Warning("Created placeholder row in " + fkey[0] + " for " + fkey[1] + " = " + fmt.Sprint(value))
+
+		Report("placeholders", placeholderEntry{
+			Table:        fkey[0],
+			Column:       fkey[1],
+			Value:        value,
+			ReferencedBy: t.name,
+			Field:        field.Name,
+			Iteration:    counter,
+			Time:         time.Now(),
+		})
+
+		return value, nil
+	default:
+		panic("Unknown fkpolicy " + policy + " on field " + field.Name)
+	}
+}
+
+// Inserts a row into a registered table with only the column equal to value, the rest is filled in like any other row
+//
+// Returns false if the insert was ignored (see IgnoreUniqueError and IgnoreFKError), so no row was created
+func createPlaceholder(table, column string, value any) (bool, error) {
+	reg, ok := registry[table]
+
+	if !ok {
+		return false, errors.New("table " + table + " must be passed to BackupTool before the tables referring to it")
+	}
+
+	t := reg.backupTable(table)
+
+	var srcKey string
+
+	for _, field := range reflect.VisibleFields(t.structType) {
+		tag, btag := getTag(field)
+
+		if tag[0] == column {
+			srcKey = btag[0]
+			break
+		}
+	}
+
+	if srcKey == "" {
+		return false, errors.New("column " + column + " not found in the schema of " + table)
+	}
+
+	// Use a copy so the row is marked as a placeholder, the summary is still shared
+	p := *t
+	p.name = table
+	p.placeholder = true
+
+	inserted := t.summary.Inserted
+
+	err := p.insertRecord(map[string]any{srcKey: value}, 0, nil)
+
+	if err != nil {
+		return false, err
+	}
+
+	// Errors ignored by IgnoreUniqueError or IgnoreFKError return nil without inserting the row
+	if t.summary.Inserted == inserted {
+		return false, nil
+	}
+
+	t.summary.Placeholders++
+
+	return true, nil
+}
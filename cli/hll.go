@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Number of bits used to select a register, giving 2^14 registers (about 1% error)
+const hllPrecision = 14
+
+// A HyperLogLog sketch counting distinct values approximately in constant memory
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+func (h *hyperLogLog) add(value string) {
+	hash := fnv.New64a()
+	hash.Write([]byte(value))
+	x := hash.Sum64()
+
+	// Mix the bits, fnv does not spread short values well enough
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Returns the estimated number of distinct values added
+func (h *hyperLogLog) count() int64 {
+	m := float64(len(h.registers))
+
+	var sum float64
+	var zeros int
+
+	for _, r := range h.registers {
+		sum += math.Pow(2, -float64(r))
+
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+
+	// Small range correction
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(math.Round(estimate))
+}
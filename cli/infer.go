@@ -0,0 +1,564 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lists the entities of a source, the built in sources implement this
+type Lister interface {
+	RecordList() ([]string, error)
+}
+
+// Returns up to n random records of a entity, sources without this are sampled using GetRecords
+type Sampler interface {
+	SampleRecords(entity string, n int) ([]map[string]any, error)
+}
+
+// Statistics of a src key over sampled records
+type FieldStats struct {
+	Key string
+	// Number of records which have the key (including nulls)
+	Present int
+	Null    int
+	// Number of non null values by column type
+	Types map[string]int
+}
+
+// Statistics of a sampled entity
+type EntityStats struct {
+	Entity  string
+	Samples int
+	Fields  []*FieldStats
+}
+
+// Returns up to n records of a entity, spread over the whole entity if the source cannot sample
+func sampleRecords(source Source, entity string, n int) ([]map[string]any, error) {
+	if sampler, ok := source.(Sampler); ok && n > 0 {
+		return sampler.SampleRecords(entity, n)
+	}
+
+	records, err := source.GetRecords(entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n <= 0 || len(records) <= n {
+		return records, nil
+	}
+
+	sample := make([]map[string]any, n)
+
+	for i := range sample {
+		sample[i] = records[i*len(records)/n]
+	}
+
+	return sample, nil
+}
+
+// Returns the entities to work on, all entities of the source if none are given
+func listEntities(source Source, entities []string) ([]string, error) {
+	if len(entities) > 0 {
+		return entities, nil
+	}
+
+	lister, ok := source.(Lister)
+
+	if !ok {
+		return nil, errors.New("source cannot list its entities, pass them as arguments")
+	}
+
+	entities, err := lister.RecordList()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(entities)
+
+	return entities, nil
+}
+
+// Computes the statistics of the top level keys of sampled records
+func inferEntity(source Source, entity string, records []map[string]any) *EntityStats {
+	stats := &EntityStats{Entity: entity, Samples: len(records)}
+
+	fields := make(map[string]*FieldStats)
+
+	for _, record := range records {
+		for key, value := range record {
+			f, ok := fields[key]
+
+			if !ok {
+				f = &FieldStats{Key: key, Types: make(map[string]int)}
+				fields[key] = f
+				stats.Fields = append(stats.Fields, f)
+			}
+
+			f.Present++
+
+			typ := valueType(source, value)
+
+			if typ == "null" {
+				f.Null++
+				continue
+			}
+
+			f.Types[typ]++
+		}
+	}
+
+	sort.Slice(stats.Fields, func(i, j int) bool {
+		return stats.Fields[i].Key < stats.Fields[j].Key
+	})
+
+	return stats
+}
+
+// Returns the column type a source value would be stored as, "null" for nulls and "[]" for empty lists
+func valueType(source Source, v any) string {
+	if v == nil {
+		return "null"
+	}
+
+	if parsed, err := source.ExtParse(v); err == nil {
+		v = parsed
+	}
+
+	switch v.(type) {
+	case string, []byte:
+		return "text"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "timestamptz"
+	case time.Duration:
+		return "interval"
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return "integer"
+	case reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "bigint"
+	case reflect.Float32, reflect.Float64:
+		return "double precision"
+	case reflect.Map:
+		return "jsonb"
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return "null"
+		}
+
+		return valueType(source, rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			return "[]"
+		}
+
+		types := make(map[string]int)
+
+		for i := 0; i < rv.Len(); i++ {
+			types[valueType(source, rv.Index(i).Interface())]++
+		}
+
+		elem := mergeTypes(types)
+
+		if elem == "jsonb" || strings.HasSuffix(elem, "[]") {
+			return "jsonb"
+		}
+
+		return elem + "[]"
+	}
+
+	return "text"
+}
+
+// Returns the type that can hold all of the given types
+func mergeTypes(types map[string]int) string {
+	var list []string
+
+	for typ := range types {
+		if typ != "null" && typ != "[]" {
+			list = append(list, typ)
+		}
+	}
+
+	if len(list) == 0 {
+		if types["[]"] > 0 {
+			return "text[]"
+		}
+
+		return "text"
+	}
+
+	if len(list) == 1 {
+		return list[0]
+	}
+
+	sort.Strings(list)
+
+	// Widen numbers
+	numeric := map[string]int{"integer": 1, "bigint": 2, "double precision": 3}
+	widest := ""
+
+	for _, typ := range list {
+		if numeric[typ] == 0 {
+			widest = ""
+			break
+		}
+
+		if numeric[typ] > numeric[widest] {
+			widest = typ
+		}
+	}
+
+	if widest != "" {
+		return widest
+	}
+
+	// Lists of different element types
+	allLists := true
+	elems := make(map[string]int)
+
+	for _, typ := range list {
+		if !strings.HasSuffix(typ, "[]") {
+			allLists = false
+			break
+		}
+
+		elems[strings.TrimSuffix(typ, "[]")]++
+	}
+
+	if allLists {
+		if elem := mergeTypes(elems); elem != "text" || elems["text"] > 0 {
+			return elem + "[]"
+		}
+	}
+
+	for _, typ := range list {
+		if typ == "jsonb" || strings.HasSuffix(typ, "[]") {
+			return "jsonb"
+		}
+	}
+
+	return "text"
+}
+
+// Returns the proposed column type
+func (f *FieldStats) Type() string {
+	return mergeTypes(f.Types)
+}
+
+// Returns true if the non null values have more than one type
+func (f *FieldStats) Conflicts() bool {
+	var n int
+
+	for typ := range f.Types {
+		if typ != "[]" {
+			n++
+		}
+	}
+
+	return n > 1
+}
+
+// Returns true if the key is missing or null in some of the samples
+func (f *FieldStats) Optional(samples int) bool {
+	return f.Present < samples || f.Null > 0
+}
+
+// Returns true if some of the values are lists
+func (f *FieldStats) hasLists() bool {
+	for typ := range f.Types {
+		if strings.HasSuffix(typ, "[]") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the proposed default of an optional field, so missing values do not need to be prompted for
+func (f *FieldStats) proposedDefault() string {
+	typ := f.Type()
+
+	switch {
+	case strings.HasSuffix(typ, "[]"), typ == "jsonb" && !f.hasLists():
+		return "{}"
+	case typ == "boolean":
+		return "false"
+	case typ == "integer" || typ == "bigint" || typ == "double precision":
+		return "0"
+	}
+
+	return "null"
+}
+
+// Returns a description of the statistics of a field
+func (f *FieldStats) describe(samples int) string {
+	str := "present " + percent(f.Present, samples) + ", null " + percent(f.Null, f.Present)
+
+	if f.Conflicts() {
+		var types []string
+
+		for typ, n := range f.Types {
+			types = append(types, typ+" "+strconv.Itoa(n))
+		}
+
+		sort.Strings(types)
+
+		str += ", conflicting types: " + strings.Join(types, ", ")
+	}
+
+	return str
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+
+	return strconv.Itoa(n*100/total) + "%"
+}
+
+// Returns the snake case form of a src key (botID -> bot_id)
+func snakeCase(s string) string {
+	runes := []rune(strings.Trim(s, "_"))
+
+	var b strings.Builder
+
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || (unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+
+			b.WriteRune(unicode.ToLower(r))
+		case r == '-' || r == ' ' || r == '.':
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// Returns a valid exported Go identifier for a snake case name, symbols are dropped and names starting with a
+// digit (or without any letters or digits) are prefixed with Field
+func goIdent(name string) string {
+	name = fieldName(name)
+
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		return "Field" + name
+	}
+
+	return name
+}
+
+// Returns a column name for a snake case name which postgres accepts without quoting
+func columnName(name string) string {
+	name = strings.Trim(strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return '_'
+	}, name), "_")
+
+	if name == "" {
+		return "field"
+	}
+
+	if unicode.IsDigit([]rune(name)[0]) {
+		return "field_" + name
+	}
+
+	return name
+}
+
+// Returns name, or name with a number appended (after sep) if it is already used, and marks it used
+func unique(name string, sep string, used map[string]bool) string {
+	res := name
+
+	for i := 2; used[res]; i++ {
+		res = name + sep + strconv.Itoa(i)
+	}
+
+	used[res] = true
+
+	return res
+}
+
+// Returns the Go type (and mark, if needed) of a field
+func (f *FieldStats) goType() (string, string) {
+	colType := f.Type()
+
+	if colType == "jsonb" && f.hasLists() {
+		return "[]any", "jsonb"
+	}
+
+	typ, ok := mappingTypes[colType]
+
+	if !ok {
+		return "string", colType
+	}
+
+	return strings.ReplaceAll(typ.String(), "interface {}", "any"), ""
+}
+
+// Returns the table mapping proposed for an entity
+func (e *EntityStats) TableMapping() TableMapping {
+	tm := TableMapping{Name: e.Entity}
+
+	names := make(map[string]bool)
+	columns := make(map[string]bool)
+
+	for _, f := range e.Fields {
+		fm := FieldMapping{
+			Name: unique(goIdent(snakeCase(f.Key)), "", names),
+			Src:  f.Key,
+			Dest: unique(columnName(snakeCase(f.Key)), "_", columns),
+			Type: f.Type(),
+		}
+
+		if fm.Type == "text" {
+			fm.Type = ""
+		}
+
+		if f.Optional(e.Samples) {
+			def := f.proposedDefault()
+
+			fm.Optional = true
+			fm.Default = &def
+			fm.NotNull = def != "null"
+		}
+
+		tm.Fields = append(tm.Fields, fm)
+	}
+
+	return tm
+}
+
+// Returns the Go schema struct proposed for an entity
+func (e *EntityStats) GoStruct() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "// Inferred from %d sampled records of %s\n", e.Samples, e.Entity)
+	fmt.Fprintf(&b, "type %s struct {\n", goIdent(snakeCase(e.Entity)))
+
+	tm := e.TableMapping()
+
+	for i, fm := range tm.Fields {
+		typ, mark := e.Fields[i].goType()
+
+		src := fm.Src
+
+		if fm.Optional {
+			src += ",omitempty"
+		}
+
+		tag := "src:" + strconv.Quote(src) + " dest:" + strconv.Quote(fm.Dest)
+
+		if mark != "" {
+			tag += " mark:" + strconv.Quote(mark)
+		}
+
+		if fm.Default != nil {
+			tag += " default:" + strconv.Quote(*fm.Default)
+		}
+
+		if fm.NotNull {
+			tag += ` notnull:"true"`
+		}
+
+		fmt.Fprintf(&b, "\t%s %s `%s` // %s\n", fm.Name, typ, tag, e.Fields[i].describe(e.Samples))
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Runs the infer command: samples entities and writes proposed schema structs and a mapping file
+func runInfer(source Source, args []string) error {
+	fs := flag.NewFlagSet("infer", flag.ExitOnError)
+	n := fs.Int("n", 1000, "Number of records to sample per entity")
+	goFile := fs.String("go", "inferred.go.example", "Write the proposed schema structs to this file")
+	mappingFile := fs.String("mapping", "inferred.yaml", "Write the proposed mapping file to this file")
+	fs.Parse(args)
+
+	entities, err := listEntities(source, fs.Args())
+
+	if err != nil {
+		return err
+	}
+
+	var code string
+	var m Mapping
+
+	for _, entity := range entities {
+		records, err := sampleRecords(source, entity, *n)
+
+		if err != nil {
+			return err
+		}
+
+		stats := inferEntity(source, entity, records)
+
+		NotifyMsg("info", "Sampled "+strconv.Itoa(stats.Samples)+" records of "+entity)
+
+		for _, f := range stats.Fields {
+			if f.Conflicts() {
+				NotifyMsg("warning", entity+"."+f.Key+": "+f.describe(stats.Samples))
+			}
+		}
+
+		code += "\n" + stats.GoStruct()
+		m.Tables = append(m.Tables, stats.TableMapping())
+	}
+
+	if strings.Contains(code, "time.") {
+		code = "import \"time\"\n" + code
+	}
+
+	formatted, err := format.Source([]byte("package main\n\n" + code))
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(*goFile, formatted, 0644); err != nil {
+		return err
+	}
+
+	bytes, err := yaml.Marshal(m)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(*mappingFile, bytes, 0644); err != nil {
+		return err
+	}
+
+	NotifyMsg("info", "Wrote "+*goFile+" and "+*mappingFile)
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"go/format"
+	"testing"
+)
+
+type testSource map[string][]map[string]any
+
+func (s testSource) GetRecords(entity string) ([]map[string]any, error) {
+	return s[entity], nil
+}
+
+func (s testSource) GetCount(entity string) (int64, error) {
+	return int64(len(s[entity])), nil
+}
+
+func (s testSource) ExtParse(res any) (any, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestInferFieldNames(t *testing.T) {
+	records := []map[string]any{
+		{"_id": "a", "id": "b", "1st": "c", "$$": "d"},
+	}
+
+	e := inferEntity(testSource{}, "users", records)
+
+	tm := e.TableMapping()
+
+	want := map[string][2]string{
+		"$$":  {"Field", "field"},
+		"1st": {"Field1st", "field_1st"},
+		"_id": {"ID", "id"},
+		"id":  {"ID2", "id_2"},
+	}
+
+	for _, fm := range tm.Fields {
+		if got := [2]string{fm.Name, fm.Dest}; got != want[fm.Src] {
+			t.Errorf("%s: got name and column %v, want %v", fm.Src, got, want[fm.Src])
+		}
+	}
+
+	if _, _, err := tm.plan(); err != nil {
+		t.Errorf("plan failed: %v", err)
+	}
+
+	if _, err := format.Source([]byte("package main\n\n" + e.GoStruct())); err != nil {
+		t.Errorf("invalid struct: %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// A secondary source entity joined into each record of a table
+type Join struct {
+	// Source entity to join
+	Entity string
+	// Src key the joined record is placed under, defaults to Entity
+	//
+	// Fields of the joined record can then be used in src tags as “As.field“ (e.g. “onboard.onboard_code“)
+	As string
+	// Src key of the record to join on
+	LocalKey string
+	// Key of the joined entity to match LocalKey against, defaults to LocalKey
+	ForeignKey string
+	// Keep the index of the joined entity on disk instead of in memory, for very large entities
+	//
+	// Records are stored as JSON, so values such as dates come back as strings. The entity is read one record at a
+	// time if the source implements Streamer, other sources still load the whole entity while building the index
+	OnDisk bool
+}
+
+// Calls fn for each record of a entity without loading them all at once, sources without this are read using GetRecords
+type Streamer interface {
+	StreamRecords(entity string, fn func(record map[string]any) error) error
+}
+
+// An index of the records of a source entity by one of their keys
+type recordIndex interface {
+	get(key string) (map[string]any, bool)
+	close()
+}
+
+type memIndex map[string]map[string]any
+
+func (m memIndex) get(key string) (map[string]any, bool) {
+	record, ok := m[key]
+	return record, ok
+}
+
+func (m memIndex) close() {}
+
+type diskIndex struct {
+	file    *os.File
+	offsets map[string][2]int64
+}
+
+func (d *diskIndex) get(key string) (map[string]any, bool) {
+	pos, ok := d.offsets[key]
+
+	if !ok {
+		return nil, false
+	}
+
+	buf := make([]byte, pos[1])
+
+	if _, err := d.file.ReadAt(buf, pos[0]); err != nil {
+		panic(err)
+	}
+
+	var record map[string]any
+
+	if err := json.Unmarshal(buf, &record); err != nil {
+		panic(err)
+	}
+
+	return record, true
+}
+
+func (d *diskIndex) close() {
+	d.file.Close()
+	os.Remove(d.file.Name())
+}
+
+// Indexes built in this run, by entity, key and storage
+var indexes = map[string]recordIndex{}
+
+// Returns the key used in indexes for a value
+func indexKey(source Source, v any) string {
+	if parsed, err := source.ExtParse(v); err == nil {
+		v = parsed
+	}
+
+	return fmt.Sprint(v)
+}
+
+// Returns an index of a source entity by key, building it if needed. Only the first record of each key is kept
+func sourceIndex(source Source, entity string, key string, onDisk bool) recordIndex {
+	cacheKey := entity + "|" + key + "|" + fmt.Sprint(onDisk)
+
+	if idx, ok := indexes[cacheKey]; ok {
+		return idx
+	}
+
+	NotifyMsg("info", "Indexing "+entity+" by "+key)
+
+	streamer, stream := source.(Streamer)
+	stream = stream && onDisk
+
+	var records []map[string]any
+
+	if !stream {
+		var err error
+		records, err = source.GetRecords(entity)
+
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if !onDisk {
+		size := approxSize(records)
+
+		if lookups.used+size > LookupMemoryLimit {
+			NotifyMsg("warning", "Index of "+entity+" exceeds the lookup memory limit, storing it on disk")
+			onDisk = true
+		} else {
+			lookups.used += size
+		}
+	}
+
+	// Calls fn for each record, streaming them from the source if possible
+	eachRecord := func(fn func(record map[string]any)) {
+		if !stream {
+			for _, record := range records {
+				fn(record)
+			}
+
+			return
+		}
+
+		err := streamer.StreamRecords(entity, func(record map[string]any) error {
+			fn(record)
+			return nil
+		})
+
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var idx recordIndex
+
+	if onDisk {
+		file, err := os.CreateTemp("", "hepatitis-antiviral-index-*.jsonl")
+
+		if err != nil {
+			panic(err)
+		}
+
+		d := &diskIndex{file: file, offsets: make(map[string][2]int64)}
+
+		var offset int64
+
+		eachRecord(func(record map[string]any) {
+			value, ok := lookupPath(record, key)
+
+			if !ok || value == nil {
+				return
+			}
+
+			k := indexKey(source, value)
+
+			if _, ok := d.offsets[k]; ok {
+				return
+			}
+
+			bytes, err := json.Marshal(normalizeRecord(source, record))
+
+			if err != nil {
+				panic(err)
+			}
+
+			if _, err := file.Write(bytes); err != nil {
+				panic(err)
+			}
+
+			d.offsets[k] = [2]int64{offset, int64(len(bytes))}
+			offset += int64(len(bytes))
+		})
+
+		idx = d
+	} else {
+		m := make(memIndex, len(records))
+
+		eachRecord(func(record map[string]any) {
+			value, ok := lookupPath(record, key)
+
+			if !ok || value == nil {
+				return
+			}
+
+			k := indexKey(source, value)
+
+			if _, ok := m[k]; !ok {
+				m[k] = record
+			}
+		})
+
+		idx = m
+	}
+
+	indexes[cacheKey] = idx
+
+	return idx
+}
+
+// Converts source specific values in a record (such as mongo dates) so it can be encoded as JSON
+func normalizeRecord(source Source, record map[string]any) map[string]any {
+	out := make(map[string]any, len(record))
+
+	for k, v := range record {
+		if parsed, err := source.ExtParse(v); err == nil {
+			v = parsed
+		}
+
+		out[k] = v
+	}
+
+	return out
+}
+
+// Closes all indexes, removing any on disk
+func closeIndexes() {
+	for key, idx := range indexes {
+		idx.close()
+		delete(indexes, key)
+	}
+}
+
+// Returns the value of a src key, keys containing dots (“a.b“) are looked up in nested documents if there is no such top level key
+func lookupPath(record map[string]any, path string) (any, bool) {
+	if v, ok := record[path]; ok {
+		return v, true
+	}
+
+	if !strings.Contains(path, ".") {
+		return nil, false
+	}
+
+	var current any = record
+
+	for _, part := range strings.Split(path, ".") {
+		rv := reflect.ValueOf(current)
+
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+
+		value := rv.MapIndex(reflect.ValueOf(part).Convert(rv.Type().Key()))
+
+		if !value.IsValid() {
+			return nil, false
+		}
+
+		current = value.Interface()
+	}
+
+	return current, true
+}
+
+// Adds the joined records to a record
+func (t *backupTable) applyJoins(record map[string]any) {
+	for _, join := range t.opts.Joins {
+		if join.LocalKey == "" {
+			panic("Join: LocalKey must be set")
+		}
+
+		as := join.As
+
+		if as == "" {
+			as = join.Entity
+		}
+
+		foreignKey := join.ForeignKey
+
+		if foreignKey == "" {
+			foreignKey = join.LocalKey
+		}
+
+		record[as] = nil
+
+		value, ok := lookupPath(record, join.LocalKey)
+
+		if !ok || value == nil {
+			continue
+		}
+
+		joined, ok := sourceIndex(t.source, join.Entity, foreignKey, join.OnDisk).get(indexKey(t.source, value))
+
+		if ok {
+			record[as] = joined
+		}
+	}
+}
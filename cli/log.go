@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"golang.org/x/exp/slices"
+)
+
+// Severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarning:
+		return "warning"
+	case LevelError:
+		return "error"
+	}
+
+	return "level(" + fmt.Sprint(int(l)) + ")"
+}
+
+// Returns the level with the given name, "warn" is accepted for warning
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warning", "warn":
+		return LevelWarning, nil
+	case "error":
+		return LevelError, nil
+	}
+
+	return LevelInfo, errors.New("unknown log level " + name + ", expected debug, info, warning or error")
+}
+
+var levelColors = map[Level]func(a ...any) string{
+	LevelDebug:   color.New(color.FgHiCyan).SprintFunc(),
+	LevelInfo:    color.New(color.FgHiGreen).SprintFunc(),
+	LevelWarning: color.New(color.FgYellow).SprintFunc(),
+	LevelError:   color.New(color.FgRed).SprintFunc(),
+}
+
+// Entries below this level are dropped
+var MinLogLevel = LevelDebug
+
+// File log entries are also written to (as JSON lines), disabled if empty
+var LogFile string
+
+// Levels are colored only if stdout is a terminal
+var logColor = isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+
+// Fields of a log entry, such as the table, field, iteration and source key of a row
+type Fields map[string]any
+
+// Fields printed first (in this order) in text output, other fields follow sorted by name
+var fieldOrder = []string{"table", "field", "column", "iteration", "key"}
+
+// Logs entries with a set of fields, use With to add fields
+type Logger struct {
+	fields Fields
+}
+
+var logMu sync.Mutex
+var logFile *os.File
+
+// Returns a logger adding the given fields to every entry
+func With(fields Fields) *Logger {
+	return (&Logger{}).With(fields)
+}
+
+// Returns a logger adding the given fields to those of this logger
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return &Logger{fields: merged}
+}
+
+func (l *Logger) Debug(msg string)   { l.Log(LevelDebug, msg) }
+func (l *Logger) Info(msg string)    { l.Log(LevelInfo, msg) }
+func (l *Logger) Warning(msg string) { l.Log(LevelWarning, msg) }
+func (l *Logger) Error(msg string)   { l.Log(LevelError, msg) }
+
+// Logs an entry if its level is at least MinLogLevel
+func (l *Logger) Log(level Level, msg string) {
+	if level < MinLogLevel {
+		return
+	}
+
+	now := time.Now()
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if LogFile != "" {
+		writeLogFile(now, level, msg, l.fields)
+	}
+
+	levelName := level.String()
+
+	if logColor {
+		levelName = levelColors[level](levelName)
+	}
+
+	line := levelName + ": " + msg + formatFields(l.fields) + "\n"
+
+	if mb == nil {
+		fmt.Print(line)
+		return
+	}
+
+	// Print above the progress bars
+	mb.Write([]byte(line))
+}
+
+// Returns the fields as " key=value ..." for text output
+func formatFields(fields Fields) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+
+	for _, k := range fieldOrder {
+		if _, ok := fields[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+
+	var rest []string
+
+	for k := range fields {
+		if !slices.Contains(fieldOrder, k) {
+			rest = append(rest, k)
+		}
+	}
+
+	sort.Strings(rest)
+
+	var b strings.Builder
+
+	for _, k := range append(keys, rest...) {
+		v := fmt.Sprint(fields[k])
+
+		if strings.ContainsAny(v, " \t\n\"=") {
+			v = fmt.Sprintf("%q", v)
+		}
+
+		b.WriteString(" " + k + "=" + v)
+	}
+
+	return b.String()
+}
+
+// Writes an entry to the log file, must be called with logMu held
+func writeLogFile(t time.Time, level Level, msg string, fields Fields) {
+	if logFile == nil {
+		var err error
+		logFile, err = os.OpenFile(LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+
+		if err != nil {
+			// Log to the terminal only from now on
+			LogFile = ""
+			fmt.Println("error: Failed to open log file:", err)
+			return
+		}
+	}
+
+	entry := make(map[string]any, len(fields)+3)
+
+	for k, v := range fields {
+		entry[k] = v
+	}
+
+	entry["time"] = t
+	entry["level"] = level.String()
+	entry["msg"] = msg
+
+	bytes, err := json.Marshal(entry)
+
+	if err != nil {
+		// Some field cannot be encoded, store fields as strings instead
+		for k, v := range fields {
+			entry[k] = fmt.Sprint(v)
+		}
+
+		bytes, _ = json.Marshal(entry)
+	}
+
+	logFile.Write(append(bytes, '\n'))
+}
+
+// Closes the log file
+func closeLog() {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+}
+
+var std = &Logger{}
+
+// Logs a message without fields, level is debug, info, warning or error (unknown levels are logged as info)
+//
+// Kept for older code, use With to log with fields
+func NotifyMsg(level string, msg string) {
+	l, err := ParseLevel(level)
+
+	if err != nil {
+		std.With(Fields{"level_name": level}).Log(LevelInfo, msg)
+		return
+	}
+
+	std.Log(l, msg)
+}
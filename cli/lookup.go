@@ -0,0 +1,339 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Approximate memory (in bytes) lookup indexes may use before falling back to postgres queries (or disk for source entities)
+var LookupMemoryLimit int64 = 512 * 1024 * 1024
+
+// Looks up rows of destination tables and source entities by key, available to transforms as TransformRow.Lookup
+//
+// Lookups are backed by hash indexes built on first use, so checking uniqueness or whether a parent exists is O(1)
+type Lookup struct {
+	source Source
+	// Destination table indexes by table and column
+	dest map[string]*destIndex
+	// Approximate memory used by all in-memory indexes
+	used int64
+	// New names of renamed tables by their old name, lookups by an old name go to the renamed table
+	renamed map[string]string
+}
+
+type destIndex struct {
+	table   string
+	column  string
+	colType string
+	rows    map[string]map[string]any
+	size    int64
+	// If the index grew past the memory limit, lookups go to postgres instead
+	overflow bool
+}
+
+var lookups = &Lookup{dest: make(map[string]*destIndex), renamed: make(map[string]string)}
+
+// Returns the first record of a source entity whose key equals value
+func (l *Lookup) Source(entity, key string, value any) (map[string]any, bool) {
+	if l.source == nil || value == nil {
+		return nil, false
+	}
+
+	return sourceIndex(l.source, entity, key, false).get(indexKey(l.source, value))
+}
+
+// Returns a row of a destination table (already inserted in this run or before it) whose column equals value
+//
+// Columns filled in by postgres defaults are not available for rows inserted in this run
+func (l *Lookup) Dest(table, column string, value any) (map[string]any, bool) {
+	if value == nil {
+		return nil, false
+	}
+
+	table = l.tableName(table)
+	idx := l.destIndex(table, column)
+
+	if idx.overflow {
+		return queryRow(table, column, lookupValue(idx.colType, value))
+	}
+
+	row, ok := idx.rows[lookupKey(idx.colType, value)]
+	return row, ok
+}
+
+// Returns true if a row of a destination table has a column equal to value
+func (l *Lookup) Exists(table, column string, value any) bool {
+	_, ok := l.Dest(table, column, value)
+	return ok
+}
+
+// Adds a row inserted outside of BackupTool (using its column names) to the indexes of its table
+func (l *Lookup) Record(table string, row map[string]any) {
+	table = l.tableName(table)
+
+	for _, idx := range l.dest {
+		if idx.table != table || idx.overflow {
+			continue
+		}
+
+		value, ok := row[idx.column]
+
+		if !ok || value == nil {
+			continue
+		}
+
+		key := lookupKey(idx.colType, value)
+
+		if _, ok := idx.rows[key]; ok {
+			continue
+		}
+
+		idx.rows[key] = row
+		l.grow(idx, approxSize(row))
+	}
+}
+
+// Returns the current name of a table, which differs if it was renamed
+func (l *Lookup) tableName(table string) string {
+	if to, ok := l.renamed[table]; ok {
+		return to
+	}
+
+	return table
+}
+
+// Moves the indexes of a table after it is renamed
+func (l *Lookup) renameTable(from, to string) {
+	l.renamed[from] = to
+
+	for old, name := range l.renamed {
+		if name == from {
+			l.renamed[old] = to
+		}
+	}
+
+	for key, idx := range l.dest {
+		if idx.table == from {
+			delete(l.dest, key)
+			idx.table = to
+			l.dest[to+"|"+idx.column] = idx
+		}
+	}
+}
+
+func (l *Lookup) destIndex(table, column string) *destIndex {
+	if idx, ok := l.dest[table+"|"+column]; ok {
+		return idx
+	}
+
+	idx := &destIndex{table: table, column: column, colType: destColType(table, column), rows: make(map[string]map[string]any)}
+	l.dest[table+"|"+column] = idx
+
+	rows, err := Pool.Query(ctx, "SELECT row_to_json(t) FROM "+pgx.Identifier{table}.Sanitize()+" t")
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var raw []byte
+
+		if err := rows.Scan(&raw); err != nil {
+			panic(err)
+		}
+
+		row := decodeRow(raw)
+
+		value, ok := row[column]
+
+		if !ok || value == nil {
+			continue
+		}
+
+		key := lookupKey(idx.colType, value)
+
+		if _, ok := idx.rows[key]; ok {
+			continue
+		}
+
+		idx.rows[key] = row
+
+		if !l.grow(idx, int64(len(raw))) {
+			break
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+
+	return idx
+}
+
+// Returns the column type of a destination column, from its schema struct if the table was registered in this run
+// and from postgres otherwise (empty if the column does not exist)
+func destColType(table, column string) string {
+	if reg, ok := registry[table]; ok {
+		for _, field := range reflect.VisibleFields(reg.structType) {
+			tag, _ := getTag(field)
+
+			if tag[0] == column && len(tag) > 1 {
+				return colType(tag)
+			}
+		}
+	}
+
+	var udt string
+
+	err := Pool.QueryRow(ctx, "SELECT udt_name FROM information_schema.columns WHERE table_name = $1 AND column_name = $2 LIMIT 1", table, column).Scan(&udt)
+
+	if err == pgx.ErrNoRows {
+		return ""
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	// Array types are named after their element type with a leading underscore
+	if strings.HasPrefix(udt, "_") {
+		return udt[1:] + "[]"
+	}
+
+	return udt
+}
+
+// Returns a value coerced to the type of the column it is looked up in, or the value as is if it cannot be
+func lookupValue(colType string, value any) any {
+	if n, ok := value.(json.Number); ok {
+		value = n.String()
+	}
+
+	if coerced, err := Coerce(colType, value, CoerceOpts{}); err == nil {
+		return coerced
+	}
+
+	return value
+}
+
+// Returns the index key of a value, so values read back from postgres (as JSON) and values given by transforms or
+// inserted in this run have the same key if they are equal in the column
+func lookupKey(colType string, value any) string {
+	switch val := lookupValue(colType, value).(type) {
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		return string(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// Accounts for memory added to an index, returns false (and drops the index) if the limit is exceeded
+func (l *Lookup) grow(idx *destIndex, size int64) bool {
+	l.used += size
+	idx.size += size
+
+	if l.used <= LookupMemoryLimit {
+		return true
+	}
+
+	NotifyMsg("warning", "Lookup index of "+idx.table+"."+idx.column+" exceeds the memory limit, falling back to postgres queries")
+
+	l.used -= idx.size
+	idx.size = 0
+	idx.rows = nil
+	idx.overflow = true
+	return false
+}
+
+// Decodes a row returned by row_to_json, keeping numbers as they are
+func decodeRow(raw []byte) map[string]any {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var row map[string]any
+
+	if err := dec.Decode(&row); err != nil {
+		panic(err)
+	}
+
+	return row
+}
+
+func queryRow(table, column string, value any) (map[string]any, bool) {
+	var raw []byte
+
+	err := Pool.QueryRow(ctx, "SELECT row_to_json(t) FROM "+pgx.Identifier{table}.Sanitize()+" t WHERE "+pgx.Identifier{column}.Sanitize()+" = $1 LIMIT 1", value).Scan(&raw)
+
+	if err == pgx.ErrNoRows {
+		return nil, false
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	return decodeRow(raw), true
+}
+
+// Returns the approximate memory used by a value
+func approxSize(v any) int64 {
+	if v == nil {
+		return 8
+	}
+
+	switch val := v.(type) {
+	case string:
+		return int64(len(val)) + 16
+	case map[string]any:
+		size := int64(48)
+
+		for k, elem := range val {
+			size += int64(len(k)) + 16 + approxSize(elem)
+		}
+
+		return size
+	case []any:
+		size := int64(24)
+
+		for _, elem := range val {
+			size += approxSize(elem)
+		}
+
+		return size
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Map:
+		size := int64(48)
+
+		iter := rv.MapRange()
+
+		for iter.Next() {
+			size += approxSize(iter.Key().Interface()) + approxSize(iter.Value().Interface())
+		}
+
+		return size
+	case reflect.Slice, reflect.Array:
+		size := int64(24)
+
+		for i := 0; i < rv.Len(); i++ {
+			size += approxSize(rv.Index(i).Interface())
+		}
+
+		return size
+	}
+
+	return 16
+}
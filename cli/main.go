@@ -2,6 +2,7 @@ package cli
 
 import (
 	"flag"
+	"os"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/joho/godotenv"
@@ -17,6 +18,35 @@ type App struct {
 	SchemaOpts SchemaOpts
 	BackupFunc func(source Source)
 	LoadSource func(name string) (Source, error)
+	// Schema structs by entity, used by the profile command to check which values cannot be coerced
+	//
+	// Use TableSchemas to build this from the tables BackupFunc backs up, so the two cannot get out of sync
+	Schemas map[string]any
+}
+
+// A table backed up by BackupTables
+type Table struct {
+	Name   string
+	Schema any
+	Opts   BackupOpts
+}
+
+// Backs up tables in order using BackupTool
+func BackupTables(source Source, tables []Table) {
+	for _, t := range tables {
+		BackupTool(source, t.Name, t.Schema, t.Opts)
+	}
+}
+
+// Returns the schema structs of tables by name, for App.Schemas
+func TableSchemas(tables []Table) map[string]any {
+	schemas := make(map[string]any, len(tables))
+
+	for _, t := range tables {
+		schemas[t.Name] = t.Schema
+	}
+
+	return schemas
 }
 
 func Main(app App) {
@@ -25,9 +55,38 @@ func Main(app App) {
 	}
 
 	OnlySchema = flag.Bool("schema", false, "Only create schema")
+	ddlFile := flag.String("ddl", "", "Write the generated schema (DDL) to this file")
+	flag.StringVar(&DeadLetterFile, "deadletter", "", "Write failed rows to this file (as JSON lines)")
+	flag.StringVar(&ReportFile, "report", "", "Write the run report (e.g. placeholder rows) to this file (as JSON)")
+	lookupMem := flag.Int64("lookup-mem", LookupMemoryLimit/1024/1024, "Memory (in MB) lookup indexes may use")
+	flag.IntVar(&DriftThreshold, "drift-threshold", DriftThreshold, "Fail the run if more source keys than this are not mapped by any field (disabled if negative)")
+	flag.StringVar(&AnswersFile, "answers", AnswersFile, "Store prompt answers in this file and replay them on later runs (disabled if empty)")
+	flag.StringVar(&PromptAddr, "prompt-addr", PromptAddr, "Address the prompt server listens on, use :34012 to listen on all interfaces")
+	flag.StringVar(&PromptCertFile, "prompt-cert", "", "TLS certificate file of the prompt server (needs -prompt-key)")
+	flag.StringVar(&PromptKeyFile, "prompt-key", "", "TLS key file of the prompt server (needs -prompt-cert)")
+	flag.StringVar(&PromptAuditFile, "prompt-audit", PromptAuditFile, "Log answers and rejected requests of the prompt server to this file (as JSON lines), disabled if empty")
+	flag.BoolVar(&NonInteractive, "non-interactive", false, "Never ask questions through the prompt server, -prompt-policy decides what happens instead")
+	promptPolicy := flag.String("prompt-policy", string(NonInteractivePolicy), "What to do with questions without a stored answer in non-interactive mode: fail (the row), zero (value of the column type), null or pending (write them to -pending and fail the row)")
+	flag.StringVar(&PendingFile, "pending", PendingFile, "CSV file pending questions are written to, answers filled in there are used by the next run")
+	mappingFile := flag.String("mapping", "", "Back up the tables of this mapping file (YAML or JSON) instead of the compiled schemas")
+	logLevel := flag.String("log-level", MinLogLevel.String(), "Only log entries of at least this level (debug, info, warning or error)")
+	flag.StringVar(&LogFile, "log-file", "", "Also write log entries to this file (as JSON lines)")
 	source := flag.String("source", "mongo", "Source to use. Must be listed in schemas.go")
 	flag.Parse()
 
+	level, err := ParseLevel(*logLevel)
+
+	if err != nil {
+		NotifyMsg("error", err.Error())
+		return
+	}
+
+	MinLogLevel = level
+
+	defer closeLog()
+
+	LookupMemoryLimit = *lookupMem * 1024 * 1024
+
 	if len(backupList) == 0 {
 		NotifyMsg("info", "No specific rows specified, backing up all")
 	}
@@ -37,6 +96,57 @@ func Main(app App) {
 		return
 	}
 
+	if flag.Arg(0) == "answers" {
+		err := runAnswers(flag.Args()[1:])
+
+		if err != nil {
+			NotifyMsg("error", "Failed to edit answers: "+err.Error())
+		}
+
+		return
+	}
+
+	if (PromptCertFile == "") != (PromptKeyFile == "") {
+		NotifyMsg("error", "-prompt-cert and -prompt-key must be given together")
+		return
+	}
+
+	if AnswersFile != "" {
+		answers, err = loadAnswers(AnswersFile)
+
+		if err != nil {
+			NotifyMsg("error", "Failed to load answers: "+err.Error())
+			return
+		}
+	}
+
+	NonInteractivePolicy, err = ParsePromptPolicy(*promptPolicy)
+
+	if err != nil {
+		NotifyMsg("error", err.Error())
+		return
+	}
+
+	if PendingFile != "" {
+		pending, err = loadPending(PendingFile)
+
+		if err != nil {
+			NotifyMsg("error", "Failed to load pending questions: "+err.Error())
+			return
+		}
+	}
+
+	var mapping *Mapping
+
+	if *mappingFile != "" {
+		mapping, err = LoadMapping(*mappingFile)
+
+		if err != nil {
+			NotifyMsg("error", "Failed to load mapping: "+err.Error())
+			return
+		}
+	}
+
 	dbSource, err := app.LoadSource(*source)
 
 	if err != nil {
@@ -44,6 +154,40 @@ func Main(app App) {
 		return
 	}
 
+	switch flag.Arg(0) {
+	case "infer":
+		err = runInfer(dbSource, flag.Args()[1:])
+
+		if err != nil {
+			NotifyMsg("error", "Failed to infer schemas: "+err.Error())
+		}
+
+		return
+	case "profile":
+		schemas := app.Schemas
+
+		if mapping != nil {
+			schemas = mapping.Schemas()
+		}
+
+		err = runProfile(dbSource, schemas, flag.Args()[1:])
+
+		if err != nil {
+			NotifyMsg("error", "Failed to profile source: "+err.Error())
+		}
+
+		return
+	}
+
+	if !NonInteractive {
+		err = startPromptServer()
+
+		if err != nil {
+			NotifyMsg("error", "Failed to start prompt server: "+err.Error())
+			return
+		}
+	}
+
 	// Create postgres conn
 	Pool, err = pgxpool.Connect(ctx, "postgresql:///"+app.SchemaOpts.TableName)
 
@@ -51,7 +195,7 @@ func Main(app App) {
 		panic(err)
 	}
 
-	_, err = Pool.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"")
+	err = execDDL("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"")
 
 	if err != nil {
 		panic(err)
@@ -66,9 +210,46 @@ COMMENT ON SCHEMA public IS 'standard public schema'`)
 		Pool.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"")
 	}
 
-	app.BackupFunc(dbSource)
+	if mapping != nil {
+		mapping.Backup(dbSource)
+	} else {
+		app.BackupFunc(dbSource)
+	}
+
+	PrintSummary()
+
+	printReport()
+
+	if ReportFile != "" {
+		err = WriteReport(ReportFile)
+
+		if err != nil {
+			NotifyMsg("error", "Failed to write report: "+err.Error())
+		}
+	}
+
+	closeIndexes()
+
+	closePromptServer()
+
+	closePending()
+
+	if *ddlFile != "" {
+		err = WriteDDL(*ddlFile)
+
+		if err != nil {
+			NotifyMsg("error", "Failed to write DDL: "+err.Error())
+		}
+	}
 
 	Bar.Abort(true)
 
 	Bar.Wait()
+
+	if err := checkDrift(); err != nil {
+		NotifyMsg("error", err.Error())
+		// Deferred calls do not run on exit
+		closeLog()
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,457 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// A mapping file, which describes tables the same way schema structs and BackupOpts do
+type Mapping struct {
+	Tables []TableMapping `yaml:"tables" json:"tables"`
+}
+
+type TableMapping struct {
+	// Name of the table (and of the source entity)
+	Name              string         `yaml:"name" json:"name"`
+	RenameTo          string         `yaml:"rename_to,omitempty" json:"rename_to,omitempty"`
+	Comment           string         `yaml:"comment,omitempty" json:"comment,omitempty"`
+	Debug             bool           `yaml:"debug,omitempty" json:"debug,omitempty"`
+	IgnoreFKError     bool           `yaml:"ignore_fk_error,omitempty" json:"ignore_fk_error,omitempty"`
+	IgnoreUniqueError bool           `yaml:"ignore_unique_error,omitempty" json:"ignore_unique_error,omitempty"`
+	IndexCols         []string       `yaml:"index_cols,omitempty" json:"index_cols,omitempty"`
+	Coerce            *CoerceMapping `yaml:"coerce,omitempty" json:"coerce,omitempty"`
+	// abort (default), skip or null
+	OnCoerceError string         `yaml:"on_coerce_error,omitempty" json:"on_coerce_error,omitempty"`
+	Joins         []JoinMapping  `yaml:"joins,omitempty" json:"joins,omitempty"`
+	KnownKeys     []string       `yaml:"known_keys,omitempty" json:"known_keys,omitempty"`
+	SourceKey     string         `yaml:"source_key,omitempty" json:"source_key,omitempty"`
+	Children      []TableMapping `yaml:"children,omitempty" json:"children,omitempty"`
+	Fields        []FieldMapping `yaml:"fields" json:"fields"`
+
+	// Only used by children, see ChildTable
+	Field      string `yaml:"field,omitempty" json:"field,omitempty"`
+	ParentKey  string `yaml:"parent_key,omitempty" json:"parent_key,omitempty"`
+	ForeignKey string `yaml:"foreign_key,omitempty" json:"foreign_key,omitempty"`
+}
+
+type CoerceMapping struct {
+	Strict      bool     `yaml:"strict,omitempty" json:"strict,omitempty"`
+	TimeLayouts []string `yaml:"time_layouts,omitempty" json:"time_layouts,omitempty"`
+	Timezone    string   `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+	EpochUnit   string   `yaml:"epoch_unit,omitempty" json:"epoch_unit,omitempty"`
+}
+
+type JoinMapping struct {
+	Entity     string `yaml:"entity" json:"entity"`
+	As         string `yaml:"as,omitempty" json:"as,omitempty"`
+	LocalKey   string `yaml:"local_key" json:"local_key"`
+	ForeignKey string `yaml:"foreign_key,omitempty" json:"foreign_key,omitempty"`
+	OnDisk     bool   `yaml:"on_disk,omitempty" json:"on_disk,omitempty"`
+}
+
+// A column, the keys match the struct tags of the same name
+type FieldMapping struct {
+	// Name of the field (used in logs), defaults to the dest column in CamelCase
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	Src  string `yaml:"src" json:"src"`
+	Dest string `yaml:"dest" json:"dest"`
+	// Column type (text, integer, bigint, boolean, timestamptz, jsonb, text[] etc.), defaults to text
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+	Mark string `yaml:"mark,omitempty" json:"mark,omitempty"`
+	// Same as omitempty in the src tag, the column is nullable
+	Optional   bool    `yaml:"optional,omitempty" json:"optional,omitempty"`
+	Default    *string `yaml:"default,omitempty" json:"default,omitempty"`
+	DefaultSQL string  `yaml:"defaultsql,omitempty" json:"defaultsql,omitempty"`
+	Unique     bool    `yaml:"unique,omitempty" json:"unique,omitempty"`
+	NotNull    bool    `yaml:"notnull,omitempty" json:"notnull,omitempty"`
+	FKey       string  `yaml:"fkey,omitempty" json:"fkey,omitempty"`
+	FKPolicy   string  `yaml:"fkpolicy,omitempty" json:"fkpolicy,omitempty"`
+	Omit       bool    `yaml:"omit,omitempty" json:"omit,omitempty"`
+	Log        bool    `yaml:"log,omitempty" json:"log,omitempty"`
+	Comment    string  `yaml:"comment,omitempty" json:"comment,omitempty"`
+	TimeFmt    string  `yaml:"timefmt,omitempty" json:"timefmt,omitempty"`
+	TZ         string  `yaml:"tz,omitempty" json:"tz,omitempty"`
+	Epoch      string  `yaml:"epoch,omitempty" json:"epoch,omitempty"`
+	// Values are redacted if true, false shows values of fields whose name looks sensitive
+	Sensitive *bool `yaml:"sensitive,omitempty" json:"sensitive,omitempty"`
+	// Registered transforms to run (in order) on the field
+	Transforms []TransformRef `yaml:"transforms,omitempty" json:"transforms,omitempty"`
+}
+
+// A registered transform, written as its name or as {name, args}
+type TransformRef struct {
+	Name string   `yaml:"name" json:"name"`
+	Args []string `yaml:"args,omitempty" json:"args,omitempty"`
+}
+
+func (t *TransformRef) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		t.Name = node.Value
+		return nil
+	}
+
+	type plain TransformRef
+	return node.Decode((*plain)(t))
+}
+
+func (t *TransformRef) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &t.Name); err == nil {
+		return nil
+	}
+
+	type plain TransformRef
+	return json.Unmarshal(data, (*plain)(t))
+}
+
+// Go types of the column types of mapping files, other types are stored as text and marked with their type
+var mappingTypes = map[string]reflect.Type{
+	"text":               reflect.TypeOf(""),
+	"smallint":           reflect.TypeOf(int16(0)),
+	"integer":            reflect.TypeOf(0),
+	"bigint":             reflect.TypeOf(int64(0)),
+	"real":               reflect.TypeOf(float32(0)),
+	"double precision":   reflect.TypeOf(float64(0)),
+	"boolean":            reflect.TypeOf(false),
+	"timestamptz":        reflect.TypeOf(time.Time{}),
+	"interval":           reflect.TypeOf(time.Duration(0)),
+	"jsonb":              reflect.TypeOf(map[string]any{}),
+	"text[]":             reflect.TypeOf([]string{}),
+	"integer[]":          reflect.TypeOf([]int{}),
+	"bigint[]":           reflect.TypeOf([]int64{}),
+	"boolean[]":          reflect.TypeOf([]bool{}),
+	"double precision[]": reflect.TypeOf([]float64{}),
+	"timestamptz[]":      reflect.TypeOf([]time.Time{}),
+}
+
+// Loads a mapping file, files ending in .json are read as JSON and all others as YAML
+func LoadMapping(filename string) (*Mapping, error) {
+	bytes, err := os.ReadFile(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var m Mapping
+
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		err = json.Unmarshal(bytes, &m)
+	} else {
+		err = yaml.Unmarshal(bytes, &m)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the plan once so errors are found before anything is backed up
+	for _, table := range m.Tables {
+		if _, _, err := table.plan(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &m, nil
+}
+
+// Backs up every table of the mapping, in order
+func (m *Mapping) Backup(source Source) {
+	for _, table := range m.Tables {
+		structType, opts, err := table.plan()
+
+		if err != nil {
+			panic(err)
+		}
+
+		BackupTool(source, table.Name, reflect.New(structType).Elem().Interface(), opts)
+	}
+}
+
+// Returns the schema structs of the tables of the mapping by name
+func (m *Mapping) Schemas() map[string]any {
+	schemas := make(map[string]any)
+
+	for _, table := range m.Tables {
+		structType, _, err := table.plan()
+
+		if err != nil {
+			panic(err)
+		}
+
+		schemas[table.Name] = reflect.New(structType).Elem().Interface()
+	}
+
+	return schemas
+}
+
+// Returns the schema struct and BackupOpts described by a table mapping
+func (tm TableMapping) plan() (reflect.Type, BackupOpts, error) {
+	if tm.Name == "" {
+		return nil, BackupOpts{}, errors.New("mapping: table without a name")
+	}
+
+	opts := BackupOpts{
+		Debug:             tm.Debug,
+		IgnoreFKError:     tm.IgnoreFKError,
+		IgnoreUniqueError: tm.IgnoreUniqueError,
+		RenameTo:          tm.RenameTo,
+		IndexCols:         tm.IndexCols,
+		Comment:           tm.Comment,
+		KnownKeys:         tm.KnownKeys,
+		SourceKey:         tm.SourceKey,
+		Transforms:        make(map[string]TransformFunc),
+	}
+
+	wrap := func(err error) error {
+		return fmt.Errorf("mapping: table %s: %w", tm.Name, err)
+	}
+
+	switch tm.OnCoerceError {
+	case "", "abort":
+		opts.OnCoerceError = AbortOnError
+	case "skip":
+		opts.OnCoerceError = SkipRowOnError
+	case "null":
+		opts.OnCoerceError = NullOnError
+	default:
+		return nil, opts, wrap(errors.New("unknown on_coerce_error " + tm.OnCoerceError))
+	}
+
+	if tm.Coerce != nil {
+		opts.Coerce = CoerceOpts{
+			Strict:      tm.Coerce.Strict,
+			TimeLayouts: tm.Coerce.TimeLayouts,
+			EpochUnit:   tm.Coerce.EpochUnit,
+		}
+
+		if tm.Coerce.Timezone != "" {
+			loc, err := time.LoadLocation(tm.Coerce.Timezone)
+
+			if err != nil {
+				return nil, opts, wrap(err)
+			}
+
+			opts.Coerce.Location = loc
+		}
+	}
+
+	for _, join := range tm.Joins {
+		if join.Entity == "" || join.LocalKey == "" {
+			return nil, opts, wrap(errors.New("joins need an entity and a local_key"))
+		}
+
+		opts.Joins = append(opts.Joins, Join(join))
+	}
+
+	if len(tm.Fields) == 0 {
+		return nil, opts, wrap(errors.New("no fields"))
+	}
+
+	var fields []reflect.StructField
+
+	names := make(map[string]bool)
+
+	for _, fm := range tm.Fields {
+		field, transform, err := fm.structField()
+
+		if err != nil {
+			return nil, opts, wrap(err)
+		}
+
+		if names[field.Name] {
+			return nil, opts, wrap(errors.New("duplicate field " + field.Name))
+		}
+
+		names[field.Name] = true
+
+		fields = append(fields, field)
+
+		if transform != nil {
+			opts.Transforms[field.Name] = transform
+		}
+	}
+
+	structType := reflect.StructOf(fields)
+
+	for _, child := range tm.Children {
+		childType, childOpts, err := child.plan()
+
+		if err != nil {
+			return nil, opts, wrap(err)
+		}
+
+		if child.Field == "" {
+			return nil, opts, wrap(errors.New("child table " + child.Name + " needs a field"))
+		}
+
+		opts.Children = append(opts.Children, ChildTable{
+			Name:       child.Name,
+			Field:      child.Field,
+			Schema:     reflect.New(childType).Elem().Interface(),
+			ParentKey:  child.ParentKey,
+			ForeignKey: child.ForeignKey,
+			Opts:       childOpts,
+		})
+	}
+
+	return structType, opts, nil
+}
+
+// Returns the struct field described by a field mapping and its (chained) transforms
+func (fm FieldMapping) structField() (reflect.StructField, TransformFunc, error) {
+	if fm.Src == "" || fm.Dest == "" {
+		return reflect.StructField{}, nil, errors.New("fields need a src and a dest")
+	}
+
+	if fm.Default != nil && fm.DefaultSQL != "" {
+		return reflect.StructField{}, nil, errors.New("field " + fm.Dest + " cannot have both default and defaultsql")
+	}
+
+	name := fm.Name
+
+	if name == "" {
+		name = fieldName(fm.Dest)
+	}
+
+	if name == "" || !unicode.IsUpper([]rune(name)[0]) {
+		return reflect.StructField{}, nil, errors.New("field name " + strconv.Quote(name) + " must start with an uppercase letter")
+	}
+
+	colType := strings.ToLower(strings.TrimSpace(fm.Type))
+
+	if colType == "" {
+		colType = "text"
+	}
+
+	goType, ok := mappingTypes[colType]
+	mark := fm.Mark
+
+	if !ok {
+		// Let postgres handle it (uuid, numeric etc.)
+		goType = reflect.TypeOf("")
+
+		if mark == "" {
+			mark = colType
+		}
+	}
+
+	src := fm.Src
+
+	if fm.Optional {
+		src += ",omitempty"
+	}
+
+	tags := [][2]string{{"src", src}, {"dest", fm.Dest}}
+
+	add := func(key, value string) {
+		tags = append(tags, [2]string{key, value})
+	}
+
+	if mark != "" {
+		add("mark", mark)
+	}
+
+	if fm.Default != nil {
+		add("default", *fm.Default)
+	}
+
+	if fm.DefaultSQL != "" {
+		add("defaultsql", fm.DefaultSQL)
+	}
+
+	if fm.Unique {
+		add("unique", "true")
+	}
+
+	if fm.NotNull {
+		add("notnull", "true")
+	}
+
+	if fm.FKey != "" {
+		add("fkey", fm.FKey)
+	}
+
+	if fm.FKPolicy != "" {
+		add("fkpolicy", fm.FKPolicy)
+	}
+
+	if fm.Omit {
+		add("omit", "true")
+	}
+
+	if fm.Log {
+		add("log", "1")
+	}
+
+	if fm.Sensitive != nil {
+		add("sensitive", strconv.FormatBool(*fm.Sensitive))
+	}
+
+	for _, tag := range [][2]string{{"comment", fm.Comment}, {"timefmt", fm.TimeFmt}, {"tz", fm.TZ}, {"epoch", fm.Epoch}} {
+		if tag[1] != "" {
+			add(tag[0], tag[1])
+		}
+	}
+
+	var tag []string
+
+	for _, t := range tags {
+		tag = append(tag, t[0]+":"+strconv.Quote(t[1]))
+	}
+
+	field := reflect.StructField{
+		Name: name,
+		Type: goType,
+		Tag:  reflect.StructTag(strings.Join(tag, " ")),
+	}
+
+	if len(fm.Transforms) == 0 {
+		return field, nil, nil
+	}
+
+	var transforms []TransformFunc
+
+	for _, ref := range fm.Transforms {
+		f, err := NamedTransform(ref.Name, ref.Args...)
+
+		if err != nil {
+			return field, nil, fmt.Errorf("field %s: %w", name, err)
+		}
+
+		transforms = append(transforms, f)
+	}
+
+	return field, Chain(transforms...), nil
+}
+
+// Parts of column names which are written in uppercase in field names
+var initialisms = []string{"id", "url", "api", "uuid", "json", "html", "http", "ip", "nsfw"}
+
+// Returns the CamelCase field name of a column (user_id -> UserID)
+func fieldName(dest string) string {
+	var name string
+
+	for _, part := range strings.FieldsFunc(dest, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if slices.Contains(initialisms, part) {
+			name += strings.ToUpper(part)
+			continue
+		}
+
+		runes := []rune(part)
+		name += string(unicode.ToUpper(runes[0])) + string(runes[1:])
+	}
+
+	return name
+}
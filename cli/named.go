@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"errors"
+	"sort"
+)
+
+// Creates a transform from the arguments given to it in a mapping file
+type TransformFactory func(args ...string) (TransformFunc, error)
+
+// Transforms which can be referenced by name in mapping files
+var namedTransforms = map[string]TransformFactory{}
+
+// Registers a transform under a name so mapping files can use it, the transform takes no arguments
+func RegisterTransform(name string, f TransformFunc) {
+	RegisterTransformFactory(name, func(args ...string) (TransformFunc, error) {
+		if len(args) > 0 {
+			return nil, errors.New("transform " + name + " takes no arguments")
+		}
+
+		return f, nil
+	})
+}
+
+// Registers a transform taking arguments under a name so mapping files can use it
+func RegisterTransformFactory(name string, factory TransformFactory) {
+	if _, ok := namedTransforms[name]; ok {
+		panic("Transform " + name + " is already registered")
+	}
+
+	namedTransforms[name] = factory
+}
+
+// Returns a registered transform
+func NamedTransform(name string, args ...string) (TransformFunc, error) {
+	factory, ok := namedTransforms[name]
+
+	if !ok {
+		return nil, errors.New("unknown transform " + name)
+	}
+
+	return factory(args...)
+}
+
+// Returns the names of all registered transforms, sorted
+func TransformNames() []string {
+	names := make([]string, 0, len(namedTransforms))
+
+	for name := range namedTransforms {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,270 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// What to do when a question needs an answer in non-interactive mode
+type PromptPolicy string
+
+const (
+	// Fail the row (default)
+	PromptFail PromptPolicy = "fail"
+	// Use the zero value of the column type
+	PromptZero PromptPolicy = "zero"
+	// Use NULL
+	PromptNull PromptPolicy = "null"
+	// Write the question to the pending questions file and fail the row, see PendingFile
+	PromptPending PromptPolicy = "pending"
+)
+
+// Questions are not asked through the prompt server if set, NonInteractivePolicy decides what happens instead
+var NonInteractive bool
+
+// What happens to questions with no stored answer in non-interactive mode
+var NonInteractivePolicy = PromptFail
+
+// CSV file unanswered questions are written to, answers filled in there are consumed by the next run
+var PendingFile = "pending.csv"
+
+var pendingHeader = []string{"table", "field", "key", "column", "type", "iteration", "message", "record", "answer"}
+
+// Pending questions file of this run
+type pendingQuestions struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *csv.Writer
+	written map[string]bool
+	// Answers read from the file, used if there is no answers file
+	answers map[string]string
+}
+
+var pending *pendingQuestions
+
+// Returns the key of a question in the pending questions file
+func pendingKey(table, field, key string) string {
+	return table + "\x00" + field + "\x00" + key
+}
+
+// Returns the policy with the given name
+func ParsePromptPolicy(name string) (PromptPolicy, error) {
+	switch p := PromptPolicy(strings.ToLower(name)); p {
+	case PromptFail, PromptZero, PromptNull, PromptPending:
+		return p, nil
+	}
+
+	return "", errors.New("unknown prompt policy " + name + ", expected fail, zero, null or pending")
+}
+
+// Reads the pending questions file and consumes its answers
+//
+// Answered questions are replayed and moved to the answers file (if any), other questions are kept in the file
+func loadPending(path string) (*pendingQuestions, error) {
+	p := &pendingQuestions{written: make(map[string]bool), answers: make(map[string]string)}
+
+	var kept [][]string
+	var unanswered int
+
+	// The file is only created when questions are written to it
+	create := NonInteractive && NonInteractivePolicy == PromptPending
+
+	f, err := os.Open(path)
+
+	if err == nil {
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+
+		rows, err := r.ReadAll()
+		f.Close()
+
+		if err != nil {
+			return nil, errors.New(path + ": " + err.Error())
+		}
+
+		var consumed int
+
+		for i, row := range rows {
+			if i == 0 || len(row) != len(pendingHeader) {
+				continue
+			}
+
+			table, field, key, answer := row[0], row[1], row[2], row[8]
+
+			if answer == "" {
+				if !p.written[pendingKey(table, field, key)] {
+					p.written[pendingKey(table, field, key)] = true
+					kept = append(kept, row)
+					unanswered++
+				}
+
+				continue
+			}
+
+			p.answers[pendingKey(table, field, key)] = answer
+			p.written[pendingKey(table, field, key)] = true
+			consumed++
+
+			if answers != nil && key != "" {
+				if err := answers.set(table, field, key, answer); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			// Keep the answer in the file as there is no answers file to move it to
+			kept = append(kept, row)
+		}
+
+		create = true
+
+		if consumed > 0 {
+			NotifyMsg("info", "Consumed "+strconv.Itoa(consumed)+" answers from "+path)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if !create {
+		return p, nil
+	}
+
+	// Rewrite the file without the consumed answers, new questions are appended while backing up
+	p.file, err = os.Create(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.w = csv.NewWriter(p.file)
+	p.w.Write(pendingHeader)
+	p.w.WriteAll(kept)
+
+	if err := p.w.Error(); err != nil {
+		return nil, err
+	}
+
+	if unanswered > 0 {
+		NotifyMsg("warning", strconv.Itoa(unanswered)+" questions in "+path+" are still unanswered")
+	}
+
+	return p, nil
+}
+
+// Returns the answer given in the pending questions file, if any
+func (p *pendingQuestions) answer(q Question) (string, bool) {
+	if p == nil || q.Key == "" {
+		return "", false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	answer, ok := p.answers[pendingKey(q.Table, q.Field, q.Key)]
+
+	return answer, ok
+}
+
+// Drops an answer read from the pending questions file so it is not replayed again
+func (p *pendingQuestions) forget(table, field, key string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.answers, pendingKey(table, field, key))
+}
+
+// Writes a question to the pending questions file, each question is written once
+func (p *pendingQuestions) add(q Question) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+
+	k := pendingKey(q.Table, q.Field, q.Key)
+
+	if q.Key != "" && p.written[k] {
+		return nil
+	}
+
+	p.written[k] = true
+
+	var record string
+
+	if q.Record != nil {
+		if bytes, err := json.Marshal(q.Record); err == nil {
+			record = string(bytes)
+		}
+	}
+
+	p.w.Write([]string{q.Table, q.Field, q.Key, q.Column, q.Type, strconv.Itoa(q.Iteration), q.Message, record, ""})
+	p.w.Flush()
+
+	return p.w.Error()
+}
+
+// Closes the pending questions file
+func closePending() {
+	if pending != nil && pending.file != nil {
+		pending.file.Close()
+	}
+}
+
+// Returns an answer which is converted to the zero value of a column type
+func zeroAnswer(colType string) string {
+	colType = strings.ToLower(colType)
+
+	switch {
+	case strings.HasSuffix(colType, "[]"):
+		return "[]"
+	case colType == "jsonb" || colType == "json":
+		return "{}"
+	case colType == "boolean" || colType == "bool":
+		return "false"
+	case colType == "timestamptz" || colType == "timestamp" || colType == "date":
+		return "0001-01-01T00:00:00Z"
+	case colType == "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case colType == "text" || colType == "varchar" || colType == "citext" || colType == "":
+		return ""
+	}
+
+	// Numbers and intervals
+	return "0"
+}
+
+// Answers a question without asking in non-interactive mode, returns a FailRow signal if the policy fails the row
+func answerNonInteractive(q Question) (string, error) {
+	switch NonInteractivePolicy {
+	case PromptZero:
+		answer := zeroAnswer(q.Type)
+		NotifyMsg("warning", "Non-interactive: using zero value "+strconv.Quote(answer)+" for: "+q.Message)
+		return answer, nil
+	case PromptNull:
+		NotifyMsg("warning", "Non-interactive: using null for: "+q.Message)
+		return "null", nil
+	case PromptPending:
+		if pending != nil {
+			if err := pending.add(q); err != nil {
+				NotifyMsg("error", "Failed to write pending question to "+PendingFile+": "+err.Error())
+			}
+		}
+
+		Report("pending_questions", q)
+
+		return "", FailRow("question written to pending questions: " + q.Message)
+	}
+
+	return "", FailRow("no answer in non-interactive mode: " + q.Message)
+}
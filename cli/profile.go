@@ -0,0 +1,331 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Maximum number of distinct values counted for top values, values first seen after this are not counted
+const profileMaxValues = 10000
+
+// Profile of a src key of an entity
+type FieldProfile struct {
+	Key string `json:"key"`
+	// Number of records with the key, including nulls
+	Present int `json:"present"`
+	Missing int `json:"missing"`
+	Null    int `json:"null"`
+	// Number of values by Go type (before source specific parsing, so BSON types are kept)
+	Types map[string]int `json:"types"`
+	// Approximate number of distinct non null values
+	Distinct int64 `json:"distinct"`
+	// Shortest and longest string (in characters) or list
+	MinLength int `json:"min_length"`
+	MaxLength int `json:"max_length"`
+	// Most common values
+	TopValues []ValueCount `json:"top_values"`
+	// Column the key is mapped to by the schema struct, if there is one
+	Column string `json:"column,omitempty"`
+	// Number of values which cannot be coerced to the column type and some examples
+	CoerceFailures int      `json:"coerce_failures"`
+	CoerceExamples []string `json:"coerce_examples,omitempty"`
+
+	distinct *hyperLogLog
+	values   map[string]int
+	lengths  bool
+	// Top values are redacted
+	sensitive bool
+}
+
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type EntityProfile struct {
+	Entity  string          `json:"entity"`
+	Records int             `json:"records"`
+	Fields  []*FieldProfile `json:"fields"`
+}
+
+func newFieldProfile(key string) *FieldProfile {
+	path := strings.Split(key, ".")
+
+	return &FieldProfile{
+		Key:       key,
+		Types:     make(map[string]int),
+		distinct:  newHyperLogLog(),
+		values:    make(map[string]int),
+		sensitive: sensitiveName(path[len(path)-1]),
+	}
+}
+
+// Adds a non missing value to the profile
+func (f *FieldProfile) add(v any) {
+	f.Present++
+
+	if v == nil {
+		f.Null++
+		return
+	}
+
+	f.Types[fmt.Sprintf("%T", v)]++
+
+	str := fmt.Sprint(v)
+
+	f.distinct.add(str)
+
+	if f.sensitive {
+		str = Redacted
+	} else if _, ok := v.(string); !ok {
+		// Documents may have sensitive keys
+		str = fmt.Sprint(redactValue(v, f.Key, nil))
+	}
+
+	str = shorten(str, 80)
+
+	if _, ok := f.values[str]; ok || len(f.values) < profileMaxValues {
+		f.values[str]++
+	}
+
+	length := -1
+
+	if s, ok := v.(string); ok {
+		length = utf8.RuneCountInString(s)
+	} else if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array || rv.Kind() == reflect.Map {
+		length = rv.Len()
+	}
+
+	if length >= 0 {
+		if !f.lengths || length < f.MinLength {
+			f.MinLength = length
+		}
+
+		if !f.lengths || length > f.MaxLength {
+			f.MaxLength = length
+		}
+
+		f.lengths = true
+	}
+}
+
+// Computes the results which are kept in sketches while values are added
+func (f *FieldProfile) finish(records int, top int) {
+	f.Missing = records - f.Present
+	f.Distinct = f.distinct.count()
+
+	if f.Present == f.Null {
+		f.Distinct = 0
+	}
+
+	for value, count := range f.values {
+		f.TopValues = append(f.TopValues, ValueCount{Value: value, Count: count})
+	}
+
+	sort.Slice(f.TopValues, func(i, j int) bool {
+		if f.TopValues[i].Count != f.TopValues[j].Count {
+			return f.TopValues[i].Count > f.TopValues[j].Count
+		}
+
+		return f.TopValues[i].Value < f.TopValues[j].Value
+	})
+
+	if len(f.TopValues) > top {
+		f.TopValues = f.TopValues[:top]
+	}
+}
+
+// Profiles the records of an entity, schema (if not nil) is the schema struct used to check coercion
+func profileEntity(source Source, entity string, records []map[string]any, schema any, top int) *EntityProfile {
+	profile := &EntityProfile{Entity: entity, Records: len(records)}
+
+	fields := make(map[string]*FieldProfile)
+
+	get := func(key string) *FieldProfile {
+		f, ok := fields[key]
+
+		if !ok {
+			f = newFieldProfile(key)
+			fields[key] = f
+			profile.Fields = append(profile.Fields, f)
+		}
+
+		return f
+	}
+
+	var schemaFields []reflect.StructField
+
+	if schema != nil {
+		schemaFields = reflect.VisibleFields(reflect.TypeOf(schema))
+
+		// Keys of the schema are listed even if no record has them
+		for _, field := range schemaFields {
+			tag, btag := getTag(field)
+			get(btag[0]).Column = tag[0]
+			// The schema decides, so sensitive:"false" shows a key with a sensitive name
+			get(btag[0]).sensitive = isSensitiveField(field)
+		}
+	}
+
+	for _, record := range records {
+		for key, value := range record {
+			get(key).add(value)
+		}
+
+		for _, field := range schemaFields {
+			tag, btag := getTag(field)
+
+			value, ok := lookupPath(record, btag[0])
+
+			if !ok || value == nil {
+				continue
+			}
+
+			if strings.Contains(btag[0], ".") {
+				if _, top := record[btag[0]]; !top {
+					get(btag[0]).add(value)
+				}
+			}
+
+			if parsed, err := source.ExtParse(value); err == nil {
+				value = parsed
+			}
+
+			_, err := Coerce(colType(tag), value, fieldCoerceOpts(field, CoerceOpts{}))
+
+			if err != nil {
+				if isSensitiveField(field) {
+					err = redactError(err)
+				}
+
+				f := get(btag[0])
+				f.CoerceFailures++
+
+				if len(f.CoerceExamples) < 3 {
+					f.CoerceExamples = append(f.CoerceExamples, err.Error())
+				}
+			}
+		}
+	}
+
+	for _, f := range profile.Fields {
+		f.finish(len(records), top)
+	}
+
+	sort.Slice(profile.Fields, func(i, j int) bool {
+		return profile.Fields[i].Key < profile.Fields[j].Key
+	})
+
+	return profile
+}
+
+var profileTemplate = template.Must(template.New("profile").Funcs(template.FuncMap{
+	"percent": percent,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Source profile</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; font-size: 14px; }
+th { background: #eee; }
+.bad { color: #b00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Source profile</h1>
+{{range .}}
+<h2>{{.Entity}} ({{.Records}} records)</h2>
+<table>
+<tr><th>Key</th><th>Column</th><th>Types</th><th>Missing</th><th>Null</th><th>Distinct</th><th>Length</th><th>Top values</th><th>Coercion failures</th></tr>
+{{$records := .Records}}
+{{range .Fields}}
+<tr>
+<td>{{.Key}}</td>
+<td>{{.Column}}</td>
+<td>{{range $type, $count := .Types}}{{$type}}: {{$count}}<br>{{end}}</td>
+<td>{{.Missing}} ({{percent .Missing $records}})</td>
+<td>{{.Null}} ({{percent .Null $records}})</td>
+<td>~{{.Distinct}}</td>
+<td>{{if .MaxLength}}{{.MinLength}} - {{.MaxLength}}{{end}}</td>
+<td>{{range .TopValues}}{{.Value}} ({{.Count}})<br>{{end}}</td>
+<td{{if .CoerceFailures}} class="bad"{{end}}>{{.CoerceFailures}}{{range .CoerceExamples}}<br>{{.}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// Runs the profile command: profiles entities and writes the profiles as HTML or JSON
+func runProfile(source Source, schemas map[string]any, args []string) error {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	n := fs.Int("n", 0, "Number of records to sample per entity, all records if 0")
+	top := fs.Int("top", 10, "Number of top values to list per field")
+	out := fs.String("out", "profile.html", "Write the profiles to this file, as JSON if it ends in .json and as HTML otherwise")
+	fs.Parse(args)
+
+	entities, err := listEntities(source, fs.Args())
+
+	if err != nil {
+		return err
+	}
+
+	var profiles []*EntityProfile
+
+	for _, entity := range entities {
+		records, err := sampleRecords(source, entity, *n)
+
+		if err != nil {
+			return err
+		}
+
+		profile := profileEntity(source, entity, records, schemas[entity], *top)
+
+		NotifyMsg("info", "Profiled "+strconv.Itoa(profile.Records)+" records of "+entity)
+
+		for _, f := range profile.Fields {
+			if f.CoerceFailures > 0 {
+				NotifyMsg("warning", entity+"."+f.Key+": "+strconv.Itoa(f.CoerceFailures)+" values cannot be coerced to the type of "+f.Column)
+			}
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	file, err := os.Create(*out)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(*out)) == ".json" {
+		enc := json.NewEncoder(file)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(profiles)
+	} else {
+		err = profileTemplate.Execute(file, profiles)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	NotifyMsg("info", "Wrote "+*out)
+
+	return nil
+}
@@ -0,0 +1,473 @@
+package cli
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Address the prompt server listens on, only local clients can connect by default
+var PromptAddr = "localhost:34012"
+
+// A question asked through the prompt server
+type Question struct {
+	ID      int    `json:"id"`
+	Message string `json:"message"`
+	// Where the question comes from, all optional
+	Table     string         `json:"table,omitempty"`
+	Field     string         `json:"field,omitempty"`
+	Column    string         `json:"column,omitempty"`
+	Iteration int            `json:"iteration,omitempty"`
+	Record    map[string]any `json:"record,omitempty"`
+	// Source key of the record, answers are stored and replayed if table, field and key are set
+	Key string `json:"key,omitempty"`
+	// Column type answers are validated against, answers are not validated if empty
+	Type string `json:"type,omitempty"`
+	// Answers are not shown in logs and the audit log (and hidden while typing in the UI)
+	Sensitive bool      `json:"sensitive,omitempty"`
+	Asked     time.Time `json:"asked"`
+
+	answer chan string
+}
+
+// Queue of pending questions, served over HTTP
+type promptServer struct {
+	mu        sync.Mutex
+	questions []*Question
+	nextID    int
+	srv       *http.Server
+	// Bearer token clients must send, see promptauth.go
+	token string
+}
+
+var prompts *promptServer
+
+// Starts the prompt server, called before backing up so a bad address or certificate fails the run up front
+func startPromptServer() error {
+	var tlsConfig *tls.Config
+
+	if PromptCertFile != "" || PromptKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(PromptCertFile, PromptKeyFile)
+
+		if err != nil {
+			return err
+		}
+
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	ln, err := net.Listen("tcp", PromptAddr)
+
+	if err != nil {
+		return err
+	}
+
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	prompts = &promptServer{token: newPromptToken()}
+
+	mux := http.NewServeMux()
+	// The UI itself is public, it reads the token from the URL fragment (which browsers never send) for API calls
+	mux.HandleFunc("/", prompts.handleUI)
+	mux.HandleFunc("/api/questions", prompts.requireToken(prompts.handleList))
+	mux.HandleFunc("/api/questions/", prompts.requireToken(prompts.handleAnswer))
+	mux.HandleFunc("/msg", prompts.requireToken(prompts.handleMsg))
+
+	prompts.srv = &http.Server{Addr: PromptAddr, Handler: mux}
+
+	go func() {
+		if err := prompts.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			NotifyMsg("error", "Prompt server stopped: "+err.Error())
+		}
+	}()
+
+	// The token is only printed to the terminal, never to the log file
+	fmt.Println("Prompt server started, open " + promptURL() + "#token=" + prompts.token + " to answer questions")
+	fmt.Println("API clients must send the header: Authorization: Bearer " + prompts.token)
+
+	return nil
+}
+
+// Returns the URL of the prompt server UI
+func promptURL() string {
+	scheme := "http://"
+
+	if PromptCertFile != "" || PromptKeyFile != "" {
+		scheme = "https://"
+	}
+
+	if strings.HasPrefix(PromptAddr, ":") {
+		return scheme + "localhost" + PromptAddr + "/"
+	}
+
+	return scheme + PromptAddr + "/"
+}
+
+// Stops the prompt server if it was started
+func closePromptServer() {
+	if prompts != nil {
+		prompts.srv.Close()
+	}
+}
+
+// Asks a question through the prompt server and waits for a (valid) answer
+//
+// Answers stored by an earlier run (see AnswersFile and PendingFile) are replayed without asking. In non-interactive
+// mode questions without a stored answer are handled by NonInteractivePolicy, which can fail the row (as a FailRow signal)
+func Prompt(q Question) (string, error) {
+	for _, replay := range []func(Question) (string, bool){replayAnswer, pending.answer} {
+		answer, ok := replay(q)
+
+		if !ok {
+			continue
+		}
+
+		if err := q.validate(answer); err != nil {
+			NotifyMsg("warning", "Ignoring stored answer for "+q.Table+"."+q.Field+" ("+q.Key+"): "+q.redactError(err).Error())
+			continue
+		}
+
+		NotifyMsg("info", "Replaying stored answer for "+q.Table+"."+q.Field+" ("+q.Key+"): "+q.shown(answer))
+		return answer, nil
+	}
+
+	if q.Record != nil {
+		if _, err := json.Marshal(q.Record); err != nil {
+			// Record has values json cannot handle, so show it as a string instead
+			q.Record = map[string]any{"raw": fmt.Sprint(q.Record)}
+		}
+	}
+
+	if NonInteractive {
+		return answerNonInteractive(q)
+	}
+
+	s := prompts
+
+	if s == nil {
+		panic("prompt server is not running")
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	q.ID = s.nextID
+	q.Asked = time.Now()
+	q.answer = make(chan string, 1)
+	s.questions = append(s.questions, &q)
+	s.mu.Unlock()
+
+	NotifyMsg("info", "To continue, please answer question "+strconv.Itoa(q.ID)+" at "+promptURL()+": "+q.Message)
+
+	answer := <-q.answer
+
+	NotifyMsg("info", "Received input: "+q.shown(answer))
+
+	recordAnswer(q, answer)
+
+	return answer, nil
+}
+
+// Asks a question through the prompt server, use Prompt to give context about the question
+//
+// Panics if the question cannot be answered in non-interactive mode, as there is no row to fail
+func PromptServerChannel(message string) string {
+	answer, err := Prompt(Question{Message: message})
+
+	if err != nil {
+		panic(err)
+	}
+
+	return answer
+}
+
+// Converts an answer to a value, "null", "true" and "false" are converted and JSON is parsed for arrays and jsonb
+func parseAnswer(answer string, colType string) any {
+	value := resolveInput(answer)
+
+	if s, ok := value.(string); ok && (strings.HasSuffix(colType, "[]") || colType == "jsonb" || colType == "json") {
+		var parsed any
+
+		if err := json.Unmarshal([]byte(s), &parsed); err == nil {
+			return parsed
+		}
+	}
+
+	return value
+}
+
+// Returns the answer as it may be logged
+func (q *Question) shown(answer string) string {
+	if q.Sensitive {
+		return Redacted
+	}
+
+	return answer
+}
+
+// Hides the answer in errors about answers to sensitive questions
+func (q *Question) redactError(err error) error {
+	if q.Sensitive {
+		return redactError(err)
+	}
+
+	return err
+}
+
+// Returns an error if an answer cannot be stored in a column of the type of the question
+func (q *Question) validate(answer string) error {
+	if q.Type == "" {
+		return nil
+	}
+
+	_, err := Coerce(q.Type, parseAnswer(answer, q.Type), CoerceOpts{})
+
+	return q.redactError(err)
+}
+
+// Answers a pending question, returns an error if there is no such question or the answer is invalid
+func (s *promptServer) answer(id int, answer string) (*Question, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, q := range s.questions {
+		if q.ID != id {
+			continue
+		}
+
+		if err := q.validate(answer); err != nil {
+			return nil, err
+		}
+
+		s.questions = append(s.questions[:i], s.questions[i+1:]...)
+		q.answer <- answer
+
+		return q, nil
+	}
+
+	return nil, errors.New("no pending question with id " + strconv.Itoa(id))
+}
+
+// Returns the pending questions
+func (s *promptServer) pending() []*Question {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Question{}, s.questions...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *promptServer) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.pending())
+}
+
+// Answers a question, POST /api/questions/{id}/answer with {"answer": "...", "by": "name"}
+func (s *promptServer) handleAnswer(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/questions/"), "/answer")
+
+	id, err := strconv.Atoi(path)
+
+	if err != nil || r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/answer") {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	var body struct {
+		Answer *string `json:"answer"`
+		// Who answered, for the audit log
+		By string `json:"by"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Answer == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "body must be {\"answer\": \"...\"}"})
+		return
+	}
+
+	q, err := s.answer(id, *body.Answer)
+
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	auditAnswer(r, q, *body.Answer, body.By)
+
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// Answers the oldest pending question with the raw body, kept for older clients
+func (s *promptServer) handleMsg(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		w.Write([]byte("Error reading body"))
+		return
+	}
+
+	pending := s.pending()
+
+	if len(pending) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("No pending questions"))
+		return
+	}
+
+	q, err := s.answer(pending[0].ID, string(body))
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	auditAnswer(r, q, string(body), "")
+}
+
+func (s *promptServer) handleUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	promptTemplate.Execute(w, nil)
+}
+
+var promptTemplate = template.Must(template.New("prompt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>hepatitis-antiviral prompts</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+.question { border: 1px solid #ccc; border-radius: 4px; padding: 1em; margin-bottom: 1em; }
+.meta { color: #666; font-size: 14px; }
+.error { color: #b00; }
+pre { background: #f6f6f6; padding: 0.5em; max-height: 20em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>Pending questions</h1>
+<p class="meta">Answer <code>null</code> for a null value. Lists and jsonb can be given as JSON.</p>
+<p>Your name (for the audit log): <input id="by"></p>
+<div id="questions">Loading...</div>
+<script>
+const typing = {};
+
+// The token is passed in the URL fragment so it never reaches the server or proxy logs
+const token = new URLSearchParams(location.hash.slice(1)).get("token") || "";
+
+const by = document.getElementById("by");
+by.value = localStorage.getItem("by") || "";
+by.oninput = () => localStorage.setItem("by", by.value);
+
+async function answer(id) {
+	const input = document.getElementById("answer-" + id);
+	const res = await fetch("/api/questions/" + id + "/answer", {
+		method: "POST",
+		headers: {"Content-Type": "application/json", "Authorization": "Bearer " + token},
+		body: JSON.stringify({answer: input.value, by: by.value}),
+	});
+	const data = await res.json();
+	if (!res.ok) {
+		document.getElementById("error-" + id).textContent = data.error;
+		return;
+	}
+	delete typing[id];
+	load();
+}
+
+function render(q) {
+	const div = document.createElement("div");
+	div.className = "question";
+
+	const h = document.createElement("h3");
+	h.textContent = "#" + q.id + ": " + q.message;
+	div.appendChild(h);
+
+	const meta = document.createElement("div");
+	meta.className = "meta";
+	meta.textContent = [q.table && "Table: " + q.table, q.field && "Field: " + q.field, q.column && "Column: " + q.column, q.type && "Type: " + q.type, q.iteration && "Row: " + q.iteration].filter(Boolean).join(" | ");
+	div.appendChild(meta);
+
+	if (q.record) {
+		const pre = document.createElement("pre");
+		pre.textContent = JSON.stringify(q.record, null, 2);
+		div.appendChild(pre);
+	}
+
+	const input = document.createElement("input");
+	input.id = "answer-" + q.id;
+	if (q.sensitive) {
+		input.type = "password";
+	}
+	input.size = 60;
+	input.value = typing[q.id] || "";
+	input.oninput = () => typing[q.id] = input.value;
+	input.onkeydown = (e) => { if (e.key === "Enter") answer(q.id); };
+	div.appendChild(input);
+
+	const button = document.createElement("button");
+	button.textContent = "Answer";
+	button.onclick = () => answer(q.id);
+	div.appendChild(button);
+
+	const error = document.createElement("div");
+	error.id = "error-" + q.id;
+	error.className = "error";
+	div.appendChild(error);
+
+	return div;
+}
+
+async function load() {
+	if (document.activeElement && document.activeElement.tagName === "INPUT") {
+		return;
+	}
+
+	const res = await fetch("/api/questions", {headers: {"Authorization": "Bearer " + token}});
+	const root = document.getElementById("questions");
+
+	if (res.status === 401) {
+		root.textContent = "Open the URL with the token printed by hepatitis-antiviral (.../#token=...)";
+		return;
+	}
+
+	const questions = await res.json();
+	root.innerHTML = "";
+
+	if (!questions.length) {
+		root.textContent = "No pending questions";
+	}
+
+	for (const q of questions) {
+		root.appendChild(render(q));
+	}
+}
+
+load();
+setInterval(load, 2000);
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TLS certificate and key files of the prompt server, served over plain HTTP if empty
+var (
+	PromptCertFile string
+	PromptKeyFile  string
+)
+
+// File every answer and rejected request of the prompt server is logged to (as JSON lines), disabled if empty
+var PromptAuditFile = "prompt_audit.jsonl"
+
+// Returns a random token for the prompt server, a new one is used for every run
+func newPromptToken() string {
+	b := make([]byte, 24)
+
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// Returns true if the request has the bearer token of the prompt server
+func (s *promptServer) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+
+	return token != header && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(s.token)) == 1
+}
+
+// Wraps a handler so it needs the bearer token, rejected requests are logged to the audit log
+func (s *promptServer) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			NotifyMsg("warning", "Rejected unauthorized prompt server request from "+r.RemoteAddr+" to "+r.URL.Path)
+
+			writeAudit(promptAudit{
+				Event:      "unauthorized",
+				Path:       r.URL.Path,
+				RemoteAddr: r.RemoteAddr,
+				UserAgent:  r.UserAgent(),
+			})
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid bearer token"})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+type promptAudit struct {
+	Event string `json:"event"`
+	Path  string `json:"path"`
+	// Question and answer, for answers
+	Question int    `json:"question,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Table    string `json:"table,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Answer   string `json:"answer,omitempty"`
+	// Who answered, as given by the client (X-Answered-By header or "by" in the body)
+	By         string    `json:"by,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Logs an answer to the audit log
+func auditAnswer(r *http.Request, q *Question, answer string, by string) {
+	if by == "" {
+		by = r.Header.Get("X-Answered-By")
+	}
+
+	if by == "" {
+		by = "unknown"
+	}
+
+	NotifyMsg("info", "Question "+strconv.Itoa(q.ID)+" answered by "+by+" from "+r.RemoteAddr)
+
+	writeAudit(promptAudit{
+		Event:      "answer",
+		Path:       r.URL.Path,
+		Question:   q.ID,
+		Message:    q.Message,
+		Table:      q.Table,
+		Field:      q.Field,
+		Key:        q.Key,
+		Answer:     q.shown(answer),
+		By:         by,
+		RemoteAddr: r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+	})
+}
+
+func writeAudit(entry promptAudit) {
+	if PromptAuditFile == "" {
+		return
+	}
+
+	entry.Time = time.Now()
+
+	bytes, err := json.Marshal(entry)
+
+	if err != nil {
+		NotifyMsg("error", "Failed to encode audit entry: "+err.Error())
+		return
+	}
+
+	file, err := os.OpenFile(PromptAuditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		NotifyMsg("error", "Failed to open prompt audit file: "+err.Error())
+		return
+	}
+
+	defer file.Close()
+
+	file.Write(append(bytes, '\n'))
+}
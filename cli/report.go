@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// File the run report is written to (as JSON), disabled if empty
+var ReportFile string
+
+// Entries of the run report by kind (e.g. placeholders), in the order they were added
+var report = map[string][]any{}
+
+// Adds an entry to the run report, entries should be JSON encodable
+//
+// The report lists things staff should review after a run, such as synthesized rows
+func Report(kind string, entry any) {
+	report[kind] = append(report[kind], entry)
+}
+
+// Returns the kinds of report entries, sorted
+func reportKinds() []string {
+	kinds := make([]string, 0, len(report))
+
+	for kind := range report {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+// Prints the number of report entries of each kind
+func printReport() {
+	for _, kind := range reportKinds() {
+		NotifyMsg("warning", "Report: "+strconv.Itoa(len(report[kind]))+" "+kind)
+	}
+}
+
+// Writes the run report to a file
+func WriteReport(filename string) error {
+	bytes, err := json.MarshalIndent(report, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, bytes, 0644)
+}
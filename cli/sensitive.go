@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Shown instead of sensitive values in logs, prompts, dead letters and reports
+const Redacted = "[redacted]"
+
+// Parts of (snake or camel case) names which mark a column or src key as sensitive, even without a sensitive tag
+var SensitiveNames = []string{"token", "secret", "password", "passwd", "auth", "p256dh", "api_key", "apikey", "private_key", "credentials"}
+
+// Returns true if a column or src key name looks like it holds a secret
+func sensitiveName(name string) bool {
+	name = "_" + snakeCase(name) + "_"
+
+	for _, part := range SensitiveNames {
+		if strings.Contains(name, "_"+part+"_") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if the values of a field must not be shown
+//
+// Fields are sensitive if they have sensitive:"true" or if their name, column or src key looks sensitive (see
+// SensitiveNames), use sensitive:"false" to show the values of a field anyway
+func isSensitiveField(field reflect.StructField) bool {
+	switch field.Tag.Get("sensitive") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	tag, btag := getTag(field)
+	path := strings.Split(btag[0], ".")
+
+	return sensitiveName(field.Name) || sensitiveName(tag[0]) || sensitiveName(path[len(path)-1])
+}
+
+// Returns whether the values of the src keys of a table must not be shown, keys of fields with neither a
+// sensitive tag nor a sensitive name are left out
+func (t *backupTable) sensitiveKeys() map[string]bool {
+	if t.sensitive == nil {
+		t.sensitive = make(map[string]bool)
+
+		for _, field := range reflect.VisibleFields(t.structType) {
+			_, btag := getTag(field)
+
+			if _, ok := field.Tag.Lookup("sensitive"); ok || isSensitiveField(field) {
+				t.sensitive[btag[0]] = isSensitiveField(field)
+			}
+		}
+	}
+
+	return t.sensitive
+}
+
+// Returns a copy of a record with sensitive values redacted, keys maps src key paths to whether they are sensitive
+// and other keys are sensitive if their name is
+func redactRecord(record map[string]any, keys map[string]bool) map[string]any {
+	if record == nil {
+		return nil
+	}
+
+	return redactValue(record, "", keys).(map[string]any)
+}
+
+// Redacts the sensitive values of documents and lists of documents, other values are returned as is
+func redactValue(v any, path string, keys map[string]bool) any {
+	if v == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+
+		for iter.Next() {
+			k := iter.Key().String()
+			value := iter.Value().Interface()
+
+			keyPath := k
+
+			if path != "" {
+				keyPath = path + "." + k
+			}
+
+			sensitive, ok := keys[keyPath]
+
+			if !ok {
+				sensitive = sensitiveName(k)
+			}
+
+			if value != nil && sensitive {
+				out[k] = Redacted
+			} else {
+				out[k] = redactValue(value, keyPath, keys)
+			}
+		}
+
+		return out
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+
+		out := make([]any, rv.Len())
+
+		for i := range out {
+			out[i] = redactValue(rv.Index(i).Interface(), path, keys)
+		}
+
+		return out
+	}
+
+	return v
+}
+
+// Returns the value to log for an insert arg
+func redactArg(sensitive bool, v any) any {
+	if sensitive && v != nil {
+		return Redacted
+	}
+
+	return redactValue(v, "", nil)
+}
+
+// Hides the values in coerce errors of a sensitive field
+func redactError(err error) error {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if ce, ok := e.(*CoerceError); ok {
+			ce.Redacted = true
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Signals which can be returned by transforms (as the value) and hooks (as the error)
+var (
+	// Skips the current row
+	ErrSkipRow = errors.New("skip row")
+	// Stops backing up the current table, rows that were already inserted are kept
+	ErrAbortTable = errors.New("abort table")
+
+	// Returned when a row is dropped by BackupOpts.Filter
+	errFiltered = errors.New("filtered")
+)
+
+// A row that failed, these are counted and written to the dead letter file
+type RowError struct {
+	Reason string
+}
+
+func (e *RowError) Error() string {
+	return "row failed: " + e.Reason
+}
+
+// Fails the current row with the given reason
+func FailRow(reason string) error {
+	return &RowError{Reason: reason}
+}
+
+// Returns a transform running fs in order, each getting the value returned by the previous one
+//
+// Stops at the first transform returning a signal (such as ErrSkipRow) and returns it
+func Chain(fs ...TransformFunc) TransformFunc {
+	if len(fs) == 1 {
+		return fs[0]
+	}
+
+	return func(tr TransformRow) any {
+		for _, f := range fs {
+			tr.CurrentValue = f(tr)
+
+			if _, ok := tr.CurrentValue.(error); ok {
+				return tr.CurrentValue
+			}
+		}
+
+		return tr.CurrentValue
+	}
+}
+
+// Same as TransformFunc but can also return an error, which is treated as a signal
+//
+// Errors other than ErrSkipRow and ErrAbortTable fail the row
+type TransformFuncE func(TransformRow) (any, error)
+
+// Converts a TransformFuncE into a TransformFunc
+func TransformE(f TransformFuncE) TransformFunc {
+	return func(tr TransformRow) any {
+		res, err := f(tr)
+
+		if err != nil {
+			return err
+		}
+
+		return res
+	}
+}
+
+// Outcome of backing up a table
+type TableSummary struct {
+	Table    string
+	Inserted int
+	Skipped  int
+	Failed   int
+	Filtered int
+	// Rows whose foreign key or unique errors were ignored
+	Ignored int
+	// Inserted rows which are placeholders for dangling foreign keys of other tables
+	Placeholders int
+	Aborted      bool
+}
+
+var summaries []*TableSummary
+
+func (s *TableSummary) String() string {
+	str := s.Table + ": " + strconv.Itoa(s.Inserted) + " inserted, " + strconv.Itoa(s.Skipped) + " skipped, " + strconv.Itoa(s.Failed) + " failed, " + strconv.Itoa(s.Filtered) + " filtered, " + strconv.Itoa(s.Ignored) + " ignored"
+
+	if s.Placeholders > 0 {
+		str += ", " + strconv.Itoa(s.Placeholders) + " placeholders"
+	}
+
+	if s.Aborted {
+		str += " (aborted)"
+	}
+
+	return str
+}
+
+func (s *TableSummary) print() {
+	level := "info"
+
+	if s.Failed > 0 || s.Aborted {
+		level = "warning"
+	}
+
+	NotifyMsg(level, "Summary for "+s.String())
+}
+
+// Prints the summary of all tables backed up in this run
+func PrintSummary() {
+	if len(summaries) == 0 {
+		return
+	}
+
+	NotifyMsg("info", "Run summary:")
+
+	for _, s := range summaries {
+		NotifyMsg("info", "    "+s.String())
+	}
+}
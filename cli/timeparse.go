@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layouts tried when parsing time strings if no other layouts are set
+var TimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	// JS Date.toString(), the timezone name in brackets is removed before parsing
+	"Mon Jan 02 2006 15:04:05 GMT-0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.UnixDate,
+}
+
+// Returns the coercion options of a field, applying the “timefmt“, “tz“ and “epoch“ tags
+func fieldCoerceOpts(field reflect.StructField, opts CoerceOpts) CoerceOpts {
+	if layouts := field.Tag.Get("timefmt"); layouts != "" {
+		opts.TimeLayouts = strings.Split(layouts, "|")
+	}
+
+	if tz := field.Tag.Get("tz"); tz != "" {
+		loc, err := loadLocation(tz)
+
+		if err != nil {
+			panic("Invalid tz tag on field " + field.Name + ": " + err.Error())
+		}
+
+		opts.Location = loc
+	}
+
+	if unit := field.Tag.Get("epoch"); unit != "" {
+		opts.EpochUnit = unit
+	}
+
+	return opts
+}
+
+// Locations of tz tags by name, so each is only loaded once and not for every row
+var (
+	locationsMu sync.Mutex
+	locations   = map[string]*time.Location{}
+)
+
+// Same as time.LoadLocation, but cached
+func loadLocation(tz string) (*time.Location, error) {
+	locationsMu.Lock()
+	defer locationsMu.Unlock()
+
+	if loc, ok := locations[tz]; ok {
+		return loc, nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+
+	if err != nil {
+		return nil, err
+	}
+
+	locations[tz] = loc
+
+	return loc, nil
+}
+
+func (opts CoerceOpts) countLayout(layout string) {
+	if opts.layoutStats != nil {
+		opts.layoutStats[layout]++
+	}
+}
+
+// Converts a unix epoch to a time
+//
+// If unit is empty, it is guessed from the size of the number (seconds, millis, micros or nanos)
+func epochToTime(n float64, unit string) (time.Time, string, error) {
+	if unit == "" {
+		abs := math.Abs(n)
+
+		switch {
+		case abs < 1e11:
+			unit = "s"
+		case abs < 1e14:
+			unit = "ms"
+		case abs < 1e17:
+			unit = "us"
+		default:
+			unit = "ns"
+		}
+	}
+
+	switch unit {
+	case "s":
+		return time.UnixMicro(int64(n * 1e6)).UTC(), unit, nil
+	case "ms":
+		return time.UnixMicro(int64(n * 1e3)).UTC(), unit, nil
+	case "us":
+		return time.UnixMicro(int64(n)).UTC(), unit, nil
+	case "ns":
+		return time.Unix(0, int64(n)).UTC(), unit, nil
+	}
+
+	return time.Time{}, unit, errors.New("invalid epoch unit " + unit)
+}
+
+func coerceTime(v any, opts CoerceOpts) (any, error) {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC(), nil
+	}
+
+	if f, ok := toFloat(v); ok {
+		t, unit, err := epochToTime(f, opts.EpochUnit)
+
+		if err == nil {
+			opts.countLayout("epoch (" + unit + ")")
+		}
+
+		return t, err
+	}
+
+	s, ok := v.(string)
+
+	if !ok {
+		return nil, errors.New("not a time")
+	}
+
+	s = strings.TrimSpace(s)
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return coerceTime(f, opts)
+	}
+
+	if strings.EqualFold(s, "now") || strings.EqualFold(s, "now()") {
+		return time.Now().UTC(), nil
+	}
+
+	// Remove the timezone name JS adds to Date.toString()
+	if i := strings.Index(s, " ("); i > 0 && strings.HasSuffix(s, ")") {
+		s = s[:i]
+	}
+
+	layouts := opts.TimeLayouts
+
+	if len(layouts) == 0 {
+		layouts = TimeLayouts
+	}
+
+	loc := opts.Location
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, s, loc)
+
+		if err == nil {
+			opts.countLayout(layout)
+			return t.UTC(), nil
+		}
+	}
+
+	return nil, errors.New("no layout matched")
+}
+
+// Prints how many time values were parsed by each layout
+func reportLayouts(schemaName string, stats map[string]int) {
+	if len(stats) == 0 {
+		return
+	}
+
+	layouts := make([]string, 0, len(stats))
+
+	for layout := range stats {
+		layouts = append(layouts, layout)
+	}
+
+	sort.Slice(layouts, func(i, j int) bool {
+		return stats[layouts[i]] > stats[layouts[j]]
+	})
+
+	NotifyMsg("info", "Time values parsed by layout for "+schemaName+":")
+
+	for _, layout := range layouts {
+		NotifyMsg("info", "    "+layout+": "+strconv.Itoa(stats[layout]))
+	}
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Called on each decoded row of a TypedBackupTool
+type RowHook[T any] func(row *T, c Context) error
+
+type TypedBackupOpts[T any] struct {
+	BackupOpts
+
+	// Called on each row after it has been decoded, before FieldHooks
+	RowHooks []RowHook[T]
+	// Called on each row after RowHooks, use Field to create these
+	FieldHooks []RowHook[T]
+}
+
+// Returns a hook transforming one field of T
+//
+// The field is selected by a function returning a pointer to it, for example:
+//
+//	cli.Field(func(b *Bot) *string { return &b.Vanity }, func(v string, c cli.Context) (string, error) { ... })
+func Field[T, F any](field func(row *T) *F, fn func(value F, c Context) (F, error)) RowHook[T] {
+	return func(row *T, c Context) error {
+		ptr := field(row)
+
+		value, err := fn(*ptr, c)
+
+		if err != nil {
+			return err
+		}
+
+		*ptr = value
+		return nil
+	}
+}
+
+// Same as BackupTool, but each source record is decoded into a T (using the src tags) so that hooks can be type checked
+//
+// Struct fields which are missing from the source record and still have their zero value after all hooks have run
+// are treated as missing, so defaults apply to them as usual
+func TypedBackupTool[T any](source Source, schemaName string, opts TypedBackupOpts[T]) {
+	var schema T
+
+	if reflect.TypeOf(schema).Kind() != reflect.Struct {
+		panic("TypedBackupTool: T must be a struct")
+	}
+
+	bopts := opts.BackupOpts
+
+	bopts.rowHook = func(record map[string]any, c Context) (map[string]any, error) {
+		var row T
+
+		present, err := decodeRecord(source, record, &row, bopts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hook := range opts.RowHooks {
+			if err := hook(&row, c); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, hook := range opts.FieldHooks {
+			if err := hook(&row, c); err != nil {
+				return nil, err
+			}
+		}
+
+		return encodeRecord(record, &row, present), nil
+	}
+
+	BackupTool(source, schemaName, schema, bopts)
+}
+
+// Decodes a source record into the struct pointed to by row, returning which src keys were present
+func decodeRecord(source Source, record map[string]any, row any, opts BackupOpts) (map[string]bool, error) {
+	rv := reflect.ValueOf(row).Elem()
+
+	present := make(map[string]bool)
+
+	for _, field := range reflect.VisibleFields(rv.Type()) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		tag, btag := getTag(field)
+
+		value, ok := lookupPath(record, btag[0])
+
+		if !ok || value == nil {
+			continue
+		}
+
+		if parsed, err := source.ExtParse(value); err == nil {
+			value = parsed
+		}
+
+		err := setField(rv.FieldByIndex(field.Index), value, colType(tag), fieldCoerceOpts(field, opts.Coerce))
+
+		if err != nil {
+			err = fmt.Errorf("cannot decode %s into field %s: %w", btag[0], field.Name, err)
+
+			switch opts.OnCoerceError {
+			case SkipRowOnError:
+				return nil, fmt.Errorf("%w: %v", ErrSkipRow, err)
+			case NullOnError:
+				NotifyMsg("warning", err.Error())
+				continue
+			default:
+				panic(err)
+			}
+		}
+
+		present[btag[0]] = true
+	}
+
+	return present, nil
+}
+
+// Sets a struct field to a source value, converting it if needed
+func setField(fv reflect.Value, value any, colType string, opts CoerceOpts) error {
+	if value == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+
+	vv := reflect.ValueOf(value)
+
+	if vv.Type().AssignableTo(fv.Type()) {
+		fv.Set(vv)
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(fv.Type().Elem())
+
+		if err := setField(elem.Elem(), value, colType, opts); err != nil {
+			return err
+		}
+
+		fv.Set(elem)
+		return nil
+	case reflect.Slice:
+		if vv.Kind() == reflect.Slice || vv.Kind() == reflect.Array {
+			list := reflect.MakeSlice(fv.Type(), vv.Len(), vv.Len())
+
+			for i := 0; i < vv.Len(); i++ {
+				if err := setField(list.Index(i), vv.Index(i).Interface(), elemType(colType), opts); err != nil {
+					return err
+				}
+			}
+
+			fv.Set(list)
+			return nil
+		}
+	}
+
+	coerced, err := Coerce(colType, value, opts)
+
+	if err != nil {
+		return err
+	}
+
+	if coerced == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+
+	cv := reflect.ValueOf(coerced)
+
+	if cv.Type().AssignableTo(fv.Type()) {
+		fv.Set(cv)
+		return nil
+	}
+
+	if cv.Kind() == reflect.Slice && fv.Kind() == reflect.Slice {
+		return setField(fv, coerced, colType, opts)
+	}
+
+	// Only convert between numbers or between values of the same kind (string -> named string type etc.)
+	_, cNum := toFloat(coerced)
+	_, fNum := toFloat(reflect.Zero(fv.Type()).Interface())
+
+	if cv.Type().ConvertibleTo(fv.Type()) && (cv.Kind() == fv.Kind() || (cNum && fNum)) {
+		fv.Set(cv.Convert(fv.Type()))
+		return nil
+	}
+
+	return errors.New("cannot set a " + cv.Type().String() + " to a " + fv.Type().String())
+}
+
+// Returns the element type of an array column type
+func elemType(colType string) string {
+	if len(colType) > 2 && colType[len(colType)-2:] == "[]" {
+		return colType[:len(colType)-2]
+	}
+
+	return colType
+}
+
+// Writes the fields of row back into a copy of the source record (under their src keys)
+func encodeRecord(record map[string]any, row any, present map[string]bool) map[string]any {
+	rv := reflect.ValueOf(row).Elem()
+
+	out := make(map[string]any, len(record))
+
+	for k, v := range record {
+		out[k] = v
+	}
+
+	for _, field := range reflect.VisibleFields(rv.Type()) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+
+		_, btag := getTag(field)
+
+		fv := rv.FieldByIndex(field.Index)
+
+		if !present[btag[0]] && fv.IsZero() {
+			continue
+		}
+
+		if fv.Kind() == reflect.Pointer && fv.IsNil() {
+			out[btag[0]] = nil
+			continue
+		}
+
+		out[btag[0]] = fv.Interface()
+	}
+
+	return out
+}
@@ -5,22 +5,23 @@ go 1.18
 require (
 	github.com/bwmarrin/discordgo v0.26.1
 	github.com/fatih/color v1.13.0
+	github.com/infinitybotlist/eureka v0.0.0-20221203142608-7547b65265c4
 	github.com/joho/godotenv v1.4.0
+	github.com/mattn/go-isatty v0.0.16
 	github.com/vbauerster/mpb/v8 v8.1.4
 	golang.org/x/exp v0.0.0-20221212164502-fae10dda9338
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/VividCortex/ewma v1.2.0 // indirect
 	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
-	github.com/infinitybotlist/eureka v0.0.0-20221203142608-7547b65265c4 // indirect
 	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
 	github.com/jackc/pgconn v1.13.0 // indirect
 	github.com/jackc/pgproto3/v2 v2.3.1 // indirect
 	github.com/jackc/puddle v1.3.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.16 // indirect
 	github.com/mattn/go-runewidth v0.0.14 // indirect
 	github.com/montanaflynn/stats v0.6.6 // indirect
 	github.com/rivo/uniseg v0.4.3 // indirect
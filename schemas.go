@@ -10,11 +10,8 @@ import (
 	"hepatitis-antiviral/sources/mongo"
 	"hepatitis-antiviral/transform"
 	"os"
-	"reflect"
-	"regexp"
 	"strings"
 	"time"
-	"unicode"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/infinitybotlist/eureka/crypto"
@@ -42,12 +39,12 @@ type Bot struct {
 	ClientID         string        `src:"clientID" dest:"client_id" unique:"true"`
 	Tags             []string      `src:"tags" dest:"tags"`
 	Prefix           *string       `src:"prefix" dest:"prefix"`
-	Owner            string        `src:"main_owner" dest:"owner" fkey:"users,user_id"`
+	Owner            string        `src:"main_owner" dest:"owner" fkey:"users,user_id" fkpolicy:"create"`
 	AdditionalOwners []string      `src:"additional_owners" dest:"additional_owners" notnull:"true"`
 	StaffBot         bool          `src:"staff" dest:"staff_bot" default:"false"`
 	Short            string        `src:"short" dest:"short"`
 	Long             string        `src:"long" dest:"long"`
-	Library          *string       `src:"library" dest:"library" default:"'custom'"`
+	Library          *string       `src:"library" dest:"library" default:"custom"`
 	ExtraLinks       []any         `src:"extra_links" dest:"extra_links" mark:"jsonb"`
 	NSFW             bool          `src:"nsfw" dest:"nsfw" default:"false"`
 	Premium          bool          `src:"premium" dest:"premium" default:"false"`
@@ -60,122 +57,58 @@ type Bot struct {
 	InviteClicks     int           `src:"invite_clicks" dest:"invite_clicks" default:"0"`
 	Banner           *string       `src:"background,omitempty" dest:"banner" default:"null"`
 	Invite           *string       `src:"invite" dest:"invite" default:"null"`
-	Type             string        `src:"type" dest:"type" default:"'pending'"`
+	Type             string        `src:"type" dest:"type" default:"pending"`
 	Vanity           *string       `src:"vanity" dest:"vanity" unique:"true"`
 	ExternalSource   string        `src:"external_source,omitempty" dest:"external_source" default:"null"`
 	ListSource       string        `src:"listSource,omitempty" dest:"list_source" mark:"uuid" default:"null"`
 	VoteBanned       bool          `src:"vote_banned,omitempty" dest:"vote_banned" default:"false" notnull:"true"`
 	CrossAdd         bool          `src:"cross_add" dest:"cross_add" default:"true" notnull:"true"`
-	StartPeriod      time.Time     `src:"start_period,omitempty" dest:"start_premium_period" default:"NOW()" notnull:"true"`
-	SubPeriod        time.Duration `src:"sub_period,omitempty" dest:"premium_period_length" default:"'12 hours'" mark:"interval" notnull:"true"`
+	StartPeriod      time.Time     `src:"start_period,omitempty" dest:"start_premium_period" defaultsql:"NOW()" notnull:"true"`
+	SubPeriod        time.Duration `src:"sub_period,omitempty" dest:"premium_period_length" default:"12 hours" mark:"interval" notnull:"true"`
 	CertReason       string        `src:"cert_reason,omitempty" dest:"cert_reason" default:"null"`
 	Announce         bool          `src:"announce,omitempty" dest:"announce" default:"false"`
 	AnnounceMessage  string        `src:"announce_msg,omitempty" dest:"announce_message" default:"null"`
 	Uptime           int64         `src:"uptime,omitempty" dest:"uptime" default:"0"`
 	TotalUptime      int64         `src:"total_uptime,omitempty" dest:"total_uptime" default:"0"`
 	ClaimedBy        string        `src:"claimedBy,omitempty" dest:"claimed_by" default:"null"`
-	Note             string        `src:"note,omitempty" dest:"approval_note" default:"'No note'" notnull:"true"`
-	Date             time.Time     `src:"date,omitempty" dest:"created_at" default:"NOW()" notnull:"true"`
-	WebAuth          *string       `src:"webAuth,omitempty" dest:"web_auth" default:"null"`
+	Note             string        `src:"note,omitempty" dest:"approval_note" default:"No note" notnull:"true"`
+	Date             time.Time     `src:"date,omitempty" dest:"created_at" defaultsql:"NOW()" notnull:"true"`
+	WebAuth          *string       `src:"webAuth,omitempty" dest:"web_auth" default:"null" sensitive:"true"`
 	WebURL           *string       `src:"webURL,omitempty" dest:"webhook" default:"null"`
 	WebHMac          *bool         `src:"webHMac" dest:"hmac" default:"false"`
 	UniqueClicks     []string      `src:"unique_clicks,omitempty" dest:"unique_clicks" default:"{}" notnull:"true"`
-	Token            string        `src:"token" dest:"api_token" default:"uuid_generate_v4()"`
+	Token            string        `src:"token" dest:"api_token" defaultsql:"uuid_generate_v4()" sensitive:"true"`
 	LastClaimed      time.Time     `src:"last_claimed,omitempty" dest:"last_claimed" default:"null"`
 }
 
-var botTransforms = map[string]cli.TransformFunc{
-	"ClaimedBy": func(tr cli.TransformRow) any {
-		if tr.CurrentValue == nil {
-			return nil
-		}
-
-		if strings.ToLower(tr.CurrentValue.(string)) == "none" || tr.CurrentValue.(string) == "" {
-			return nil
-		}
-
-		return tr.CurrentValue
-	},
-	"StartPeriod": func(tr cli.TransformRow) any {
-		if tr.CurrentValue == nil {
-			return nil
-		}
-
-		switch val := tr.CurrentValue.(type) {
-		case time.Time:
-			return val
-		case int32:
-			return time.Unix(0, int64(val*int32(time.Millisecond)))
-		case int64:
-			return time.Unix(0, int64(val*int64(time.Millisecond)))
-		case float64:
-			return time.Unix(0, int64(val*float64(time.Millisecond)))
-		}
-
-		panic("invalid type for StartPeriod")
-	},
-	"SubPeriod": func(tr cli.TransformRow) any {
-		// Convert to time.Duration
-		if tr.CurrentValue == nil {
-			return nil
+// Derives the type of a bot
+func botRowTransform(record map[string]any) (map[string]any, error) {
+	// Empty strings are null, as they are for fields
+	if record["type"] == "" {
+		record["type"] = nil
+	}
+
+	if record["type"] == nil {
+		record["type"] = "approved"
+	} else if val, ok := record["certified"].(bool); ok && val {
+		record["type"] = "certified"
+	} else if record["type"] != "approved" && record["type"] != "denied" {
+		if val, ok := record["claimed"].(bool); ok && val {
+			record["type"] = "claimed"
 		}
+	}
 
-		switch val := tr.CurrentValue.(type) {
-		case time.Duration:
-			return val
-		case int32:
-			return time.Duration(val) * time.Millisecond
-		case int64:
-			return time.Duration(val) * time.Millisecond
-		case float64:
-			return time.Duration(val) * time.Millisecond
-		}
-
-		panic("invalid type for SubPeriod: " + reflect.TypeOf(tr.CurrentValue).String())
-	},
-	"Type": func(tr cli.TransformRow) any {
-		if tr.CurrentValue == nil {
-			return "approved"
-		}
-
-		if val, ok := tr.CurrentRecord["certified"].(bool); ok && val {
-			return "certified"
-		}
-
-		if tr.CurrentValue == "approved" || tr.CurrentValue == "denied" {
-			return tr.CurrentValue
-		}
-
-		if val, ok := tr.CurrentRecord["claimed"].(bool); ok && val {
-			return "claimed"
-		}
+	return record, nil
+}
 
-		return tr.CurrentValue
-	},
+var botTransforms = map[string]cli.TransformFunc{
+	"BotID":      transform.Snowflake(transform.SnowflakeOpts{}),
+	"Date":       transform.SnowflakeTimestamp("botID"),
+	"ExtraLinks": transform.Links("Website", "Support", "Github", "Donate"),
+	"ClaimedBy":  transform.Chain(transform.Trim, transform.NullIfFold("none")),
 	"UniqueClicks": func(tr cli.TransformRow) any {
 		return []string{}
 	},
-	"ExtraLinks": func(tr cli.TransformRow) any {
-		var links = []link{}
-
-		for _, cols := range []string{"Website", "Support", "Github", "Donate"} {
-			col := strings.ToLower(cols)
-			if tr.CurrentRecord[col] != nil {
-				value := parseLink(col, tr.CurrentRecord[col].(string))
-
-				if value == "" {
-					continue
-				}
-
-				links = append(links, link{
-					Name:  cols,
-					Value: value,
-				})
-			}
-		}
-
-		return links
-	},
 	"ClientID": transform.DefaultTransform(func(tr cli.TransformRow) any {
 		botId := tr.CurrentRecord["botID"].(string)
 
@@ -195,17 +128,29 @@ var botTransforms = map[string]cli.TransformFunc{
 		}
 
 		for rerr != nil {
-			clientId := cli.PromptServerChannel("What is the client ID for " + botId + "?")
+			clientId, err := tr.Prompt("What is the client ID for " + botId + "?")
+
+			if err != nil {
+				return err
+			}
 
 			if clientId == "DEL" {
 				source.Conn.Database("infinity").Collection("bots").DeleteOne(context.Background(), bson.M{"botID": botId})
-				return "SKIP"
+				return cli.ErrSkipRow
 			}
 
 			_, rerr = sess.Request("GET", "https://discord.com/api/v10/applications/"+clientId+"/rpc", nil)
 
 			if rerr != nil {
 				fmt.Println("Client ID fetch error:", rerr)
+				// Ask again next time if this was a stored answer
+				tr.ForgetAnswer()
+
+				if cli.NonInteractive {
+					// Nobody can give a different answer
+					return cli.FailRow("invalid client ID " + clientId)
+				}
+
 				continue
 			}
 
@@ -224,77 +169,15 @@ var botTransforms = map[string]cli.TransformFunc{
 	}),
 	"AdditionalOwners": transform.ToList,
 	"Tags":             transform.ToList,
-	"Owner": func(tr cli.TransformRow) any {
-		if tr.CurrentValue == nil {
-			return nil
-		}
-
-		userId := tr.CurrentValue.(string)
-
-		userId = strings.TrimSpace(userId)
-
-		var count int64
-
-		err := cli.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM users WHERE user_id = $1", userId).Scan(&count)
-
-		if err != nil {
-			panic(err)
-		}
-
-		if count == 0 {
-			cli.NotifyMsg("warning", "User not found, adding")
-
-			if _, err = cli.Pool.Exec(ctx, "INSERT INTO users (user_id, api_token, extra_links) VALUES ($1, $2, $3)", userId, crypto.RandString(128), []link{}); err != nil {
-				panic(err)
-			}
-		}
-
-		return userId
-	},
-	"Vanity": func(tr cli.TransformRow) any {
-		if tr.CurrentValue == nil {
-			cli.NotifyMsg("error", "Got nil name for current context: "+fmt.Sprint(tr.CurrentRecord["botID"]))
-			panic("Got nil name")
-		}
-
-		name := tr.CurrentValue.(string)
-
-		// Strip out unicode characters
-		name = strings.Map(func(r rune) rune {
-			if r > unicode.MaxASCII {
-				return -1
-			}
-			return r
-		}, name)
-
-		if name == "" {
-			panic("Got empty name")
-		}
-
-		name = strings.TrimSuffix(name, "-")
-
-		// Check if vanity is taken
-		var count int64
-
-		err := cli.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM bots WHERE lower(vanity) = $1", strings.ToLower(name)).Scan(&count)
-
-		if err != nil {
-			panic(err)
-		}
-
-		if count != 0 {
-			return strings.ToLower(name) + "-" + crypto.RandString(12)
-		}
-
-		return strings.ToLower(name)
-	},
+	"Owner":            transform.Snowflake(transform.SnowflakeOpts{}),
+	"Vanity":           transform.Slug(transform.SlugOpts{Table: "bots", Column: "vanity", Strategy: transform.SlugRandom}),
 }
 
 type ActionLog struct {
 	BotID     string    `src:"botID" dest:"bot_id" fkey:"bots,bot_id"`
 	StaffID   string    `src:"staff_id" dest:"staff_id" fkey:"users,user_id"`
-	ActReason string    `src:"reason" dest:"action_reason" default:"'No reason'"`
-	Timestamp time.Time `src:"ts" dest:"ts" default:"NOW()"`
+	ActReason string    `src:"reason" dest:"action_reason" default:"No reason"`
+	Timestamp time.Time `src:"ts" dest:"ts" defaultsql:"NOW()"`
 	Event     string    `src:"event" dest:"event"`
 }
 
@@ -302,8 +185,8 @@ type Claims struct {
 	BotID       string    `src:"botID" dest:"bot_id" unique:"true" fkey:"bots,bot_id"`
 	ClaimedBy   string    `src:"claimedBy" dest:"claimed_by"`
 	Claimed     bool      `src:"claimed" dest:"claimed"`
-	ClaimedAt   time.Time `src:"claimedAt" dest:"claimed_at" default:"NOW()"`
-	UnclaimedAt time.Time `src:"unclaimedAt" dest:"unclaimed_at" default:"NOW()"`
+	ClaimedAt   time.Time `src:"claimedAt" dest:"claimed_at" defaultsql:"NOW()"`
+	UnclaimedAt time.Time `src:"unclaimedAt" dest:"unclaimed_at" defaultsql:"NOW()"`
 }
 
 type OnboardData struct {
@@ -316,12 +199,12 @@ type User struct {
 	UserID                    string    `src:"userID" dest:"user_id" unique:"true" default:"SKIP"`
 	Experiments               []string  `src:"experiments" dest:"experiments" default:"{}"`
 	StaffOnboarded            bool      `src:"staff_onboarded" dest:"staff_onboarded" default:"false"`
-	StaffOnboardState         string    `src:"staff_onboard_state" dest:"staff_onboard_state" default:"'pending'"`
+	StaffOnboardState         string    `src:"staff_onboard_state" dest:"staff_onboard_state" default:"pending"`
 	StaffOnboardLastStartTime time.Time `src:"staff_onboard_last_start_time,omitempty" dest:"staff_onboard_last_start_time" default:"null"`
 	StaffOnboardMacroTime     time.Time `src:"staff_onboard_macro_time,omitempty" dest:"staff_onboard_macro_time" default:"null"`
 	StaffOnboardSessionCode   string    `src:"staff_onboard_session_code,omitempty" dest:"staff_onboard_session_code,omitempty" default:"null"`
 	StaffOnboardGuild         string    `src:"staff_onboard_guild,omitempty" dest:"staff_onboard_guild,omitempty" default:"null"`
-	StaffRPCLastVerify        time.Time `src:"staff_rpc_last_verify" dest:"staff_rpc_last_verify" default:"NOW() - interval '1 hour'"`
+	StaffRPCLastVerify        time.Time `src:"staff_rpc_last_verify" dest:"staff_rpc_last_verify" defaultsql:"NOW() - interval '1 hour'"`
 	Staff                     bool      `src:"staff" dest:"staff" default:"false"`
 	Admin                     bool      `src:"admin" dest:"admin" default:"false"`
 	HAdmin                    bool      `src:"hadmin" dest:"hadmin" default:"false"`
@@ -332,56 +215,28 @@ type User struct {
 	Developer                 bool      `src:"developer" dest:"developer" default:"false"`
 	CaptchaSponsorEnabled     bool      `src:"captcha_sponsor_enabled" dest:"captcha_sponsor_enabled" default:"true"`
 	ExtraLinks                []any     `src:"extra_links" dest:"extra_links" mark:"jsonb"`
-	APIToken                  string    `src:"apiToken" dest:"api_token"`
-	About                     *string   `src:"about,omitempty" dest:"about" default:"'I am a very mysterious person'"`
+	APIToken                  string    `src:"apiToken" dest:"api_token" sensitive:"true"`
+	About                     *string   `src:"about,omitempty" dest:"about" default:"I am a very mysterious person"`
 	VoteBanned                bool      `src:"vote_banned" dest:"vote_banned" default:"false"`
 	Banned                    bool      `src:"banned" dest:"banned" default:"false"`
 }
 
 var userTransforms = map[string]cli.TransformFunc{
-	"UserID": func(tr cli.TransformRow) any {
-		if tr.CurrentValue == nil {
-			return tr.CurrentValue
-		}
-
-		userId := tr.CurrentValue.(string)
-
-		return strings.TrimSpace(userId)
-	},
+	"UserID":     transform.Snowflake(transform.SnowflakeOpts{}),
+	"ExtraLinks": transform.Links("Website", "Github"),
 	"APIToken": transform.DefaultTransform(func(tr cli.TransformRow) any {
 		return crypto.RandString(128)
 	}),
-	"ExtraLinks": func(tr cli.TransformRow) any {
-		var links = []link{}
-
-		for _, cols := range []string{"Website", "Github"} {
-			col := strings.ToLower(cols)
-			if tr.CurrentRecord[col] != nil {
-				value := parseLink(col, tr.CurrentRecord[col].(string))
-
-				if value == "" {
-					continue
-				}
-
-				links = append(links, link{
-					Name:  cols,
-					Value: value,
-				})
-			}
-		}
-
-		return links
-	},
 }
 
 type Announcements struct {
 	UserID         string    `src:"userID" dest:"user_id" fkey:"users,user_id"`
-	AnnouncementID string    `src:"announceID" dest:"id" mark:"uuid" default:"uuid_generate_v4()" omit:"true"`
+	AnnouncementID string    `src:"announceID" dest:"id" mark:"uuid" defaultsql:"uuid_generate_v4()" omit:"true"`
 	Title          string    `src:"title" dest:"title"`
 	Content        string    `src:"content" dest:"content"`
-	ModifiedDate   time.Time `src:"modifiedDate" dest:"modified_date" default:"NOW()"`
-	ExpiresDate    time.Time `src:"expiresDate,omitempty" dest:"expires_date" default:"NOW()"`
-	Status         string    `src:"status" dest:"status" default:"'active'"`
+	ModifiedDate   time.Time `src:"modifiedDate" dest:"modified_date" defaultsql:"NOW()"`
+	ExpiresDate    time.Time `src:"expiresDate,omitempty" dest:"expires_date" defaultsql:"NOW()"`
+	Status         string    `src:"status" dest:"status" default:"active"`
 	Targetted      bool      `src:"targetted" dest:"targetted" default:"false"`
 	Target         []string  `src:"target,omitempty" dest:"target" default:"null"`
 }
@@ -393,47 +248,42 @@ var announcementTransforms = map[string]cli.TransformFunc{
 type Votes struct {
 	UserID string    `src:"userID" dest:"user_id" fkey:"users,user_id" fkignore:"true"`
 	BotID  string    `src:"botID" dest:"bot_id" fkey:"bots,bot_id"`
-	Date   time.Time `src:"date" dest:"created_at" default:"NOW()"`
+	Date   time.Time `src:"date" dest:"created_at" defaultsql:"NOW()"`
 }
 
 type PackVotes struct {
 	UserID string    `src:"userID" dest:"user_id" fkey:"users,user_id"`
 	URL    string    `src:"url" dest:"url" fkey:"packs,url"`
 	Upvote bool      `src:"upvote" dest:"upvote"`
-	Date   time.Time `src:"date" dest:"created_at" default:"NOW()"`
+	Date   time.Time `src:"date" dest:"created_at" defaultsql:"NOW()"`
 }
 
 type Packs struct {
 	Owner string    `src:"owner" dest:"owner" fkey:"users,user_id"`
-	Name  string    `src:"name" dest:"name" default:"'My pack'"`
+	Name  string    `src:"name" dest:"name" default:"My pack"`
 	Short string    `src:"short" dest:"short"`
 	Tags  []string  `src:"tags" dest:"tags"`
 	URL   string    `src:"url" dest:"url" unique:"true"`
-	Date  time.Time `src:"date" dest:"created_at" default:"NOW()"`
+	Date  time.Time `src:"date" dest:"created_at" defaultsql:"NOW()"`
 	Bots  []string  `src:"bots" dest:"bots"`
 }
 
 var packTransforms = map[string]cli.TransformFunc{
 	"Tags": transform.ToList,
 	"Bots": transform.ToList,
-	"URL": func(tr cli.TransformRow) any {
-		if tr.CurrentValue == nil {
-			return crypto.RandString(12)
-		}
-
-		reg, _ := regexp.Compile("[^a-zA-Z0-9 ]+")
-
-		return reg.ReplaceAllString(tr.CurrentValue.(string), "")
-	},
+	// Existing URLs are kept as pack_votes refers to them, only duplicates are renamed
+	"URL": transform.Chain(transform.Slug(transform.SlugOpts{Table: "packages", Column: "url", Strategy: transform.SlugNumeric, KeepExisting: true}), transform.DefaultTransform(func(tr cli.TransformRow) any {
+		return strings.ToLower(crypto.RandString(12))
+	})),
 }
 
 type Reviews struct {
-	ID       string    `src:"review_id" unique:"true" dest:"id" mark:"uuid" default:"uuid_generate_v4()" omit:"true"`
+	ID       string    `src:"review_id" unique:"true" dest:"id" mark:"uuid" defaultsql:"uuid_generate_v4()" omit:"true"`
 	BotID    string    `src:"botID" dest:"bot_id" fkey:"bots,bot_id"`
 	Author   string    `src:"author" dest:"author" fkey:"users,user_id"`
-	Content  string    `src:"content" dest:"content" default:"'Very good bot!'"`
+	Content  string    `src:"content" dest:"content" default:"Very good bot!"`
 	StarRate int       `src:"star_rate" dest:"stars" default:"1"`
-	Date     time.Time `src:"date" dest:"created_at" default:"NOW()"`
+	Date     time.Time `src:"date" dest:"created_at" defaultsql:"NOW()"`
 	ParentID string    `src:"parentID,omitempty" dest:"parent_id" mark:"uuid" default:"null"`
 }
 
@@ -444,15 +294,20 @@ var reviewTransforms = map[string]cli.TransformFunc{
 type Tickets struct {
 	ChannelID     string    `src:"channelID" dest:"channel_id"`
 	TopicID       string    `src:"topicID" dest:"topic_id"`
-	Topic         string    `src:"topic" dest:"topic" mark:"jsonb" default:"'{}'"`
+	Topic         string    `src:"topic" dest:"topic" mark:"jsonb" default:"{}"`
 	Issue         string    `src:"issue" dest:"issue"`
-	TicketContext string    `src:"ticketContext" dest:"ticket_context" mark:"jsonb" default:"'{}'"`
-	Messages      string    `src:"messages" dest:"messages" mark:"jsonb" default:"'{}'"`
-	UserID        string    `src:"userID" dest:"user_id"` // No fkey here bc a user may not be a user on the table yet
+	TicketContext string    `src:"ticketContext" dest:"ticket_context" mark:"jsonb" default:"{}"`
+	Messages      string    `src:"messages" dest:"messages" mark:"jsonb" default:"{}"`
+	UserID        string    `src:"userID" dest:"user_id" comment:"No fkey here as the user may not be on the users table yet"`
 	TicketID      string    `src:"ticketID" dest:"id" unique:"true"`
 	CloseUserID   string    `src:"closeUserID,omitempty" dest:"close_user_id" default:"null"`
 	Open          bool      `src:"open" dest:"open" default:"true"`
-	Date          time.Time `src:"date" dest:"created_at" default:"NOW()"`
+	Date          time.Time `src:"date" dest:"created_at" defaultsql:"NOW()"`
+}
+
+var ticketTransforms = map[string]cli.TransformFunc{
+	// Tickets are channels, so the channel was created when the ticket was opened
+	"Date": transform.SnowflakeTimestamp("channelID"),
 }
 
 type Alerts struct {
@@ -466,24 +321,24 @@ type Alerts struct {
 type Poppypaw struct {
 	UserID    string    `src:"id" dest:"user_id" fkey:"users,user_id"`
 	NotifID   string    `src:"notifId" dest:"notif_id"`
-	Auth      string    `src:"auth" dest:"auth"`
-	P256dh    string    `src:"p256dh" dest:"p256dh"`
+	Auth      string    `src:"auth" dest:"auth" sensitive:"true"`
+	P256dh    string    `src:"p256dh" dest:"p256dh" sensitive:"true"`
 	Endpoint  string    `src:"endpoint" dest:"endpoint"`
-	CreatedAt time.Time `src:"createdAt" dest:"created_at" default:"NOW()"`
-	UA        string    `src:"ua" dest:"ua" default:"''"`
+	CreatedAt time.Time `src:"createdAt" dest:"created_at" defaultsql:"NOW()"`
+	UA        string    `src:"ua" dest:"ua" default:""`
 }
 
 type Silverpelt struct {
 	UserID    string    `src:"userID" dest:"user_id" fkey:"users,user_id"`
 	BotID     string    `src:"botID" dest:"bot_id" fkey:"bots,bot_id"`
-	CreatedAt time.Time `src:"createdAt" dest:"created_at" default:"NOW()"`
-	LastAcked time.Time `src:"lastAcked" dest:"last_acked" default:"NOW()"`
+	CreatedAt time.Time `src:"createdAt" dest:"created_at" defaultsql:"NOW()"`
+	LastAcked time.Time `src:"lastAcked" dest:"last_acked" defaultsql:"NOW()"`
 }
 
 type RPCRequests struct {
 	UserID    string    `src:"userID" dest:"user_id" fkey:"users,user_id"`
 	Method    string    `src:"method" dest:"method"`
-	CreatedAt time.Time `src:"createdAt" dest:"created_at" default:"NOW()"`
+	CreatedAt time.Time `src:"createdAt" dest:"created_at" defaultsql:"NOW()"`
 }
 
 type Apps struct {
@@ -491,10 +346,10 @@ type Apps struct {
 	UserID         string         `src:"userID" dest:"user_id" fkey:"users,user_id"`
 	Position       string         `src:"position" dest:"position"`
 	ReviewFeedback string         `src:"review_feedback,omitempty" dest:"review_feedback" default:"null"`
-	CreatedAt      time.Time      `src:"createdAt" dest:"created_at" default:"NOW()"`
+	CreatedAt      time.Time      `src:"createdAt" dest:"created_at" defaultsql:"NOW()"`
 	Questions      map[string]any `src:"questions" dest:"questions" default:"{}"`
 	Answers        map[string]any `src:"answers" dest:"answers" default:"{}"`
-	State          string         `src:"state" dest:"state" default:"'pending'"`
+	State          string         `src:"state" dest:"state" default:"pending"`
 }
 
 type Blog struct {
@@ -502,12 +357,66 @@ type Blog struct {
 	Title       string    `src:"title" dest:"title"`
 	Description string    `src:"description" dest:"description"`
 	UserID      string    `src:"userID" dest:"user_id" fkey:"users,user_id"`
-	CreatedAt   time.Time `src:"createdAt" dest:"created_at" default:"NOW()"`
+	CreatedAt   time.Time `src:"createdAt" dest:"created_at" defaultsql:"NOW()"`
 	Content     string    `src:"content" dest:"content"`
 	Draft       bool      `src:"draft" dest:"draft"`
 	Tags        []string  `src:"tags" dest:"tags"`
 }
 
+// Tables to back up, in order
+var tables = []cli.Table{
+	{Name: "users", Schema: User{}, Opts: cli.BackupOpts{
+		IgnoreFKError:     true,
+		IgnoreUniqueError: true,
+		Transforms:        userTransforms,
+		// Read by the ExtraLinks transform
+		KnownKeys: []string{"website", "github"},
+	}},
+	{Name: "apps", Schema: Apps{}, Opts: cli.BackupOpts{}},
+	{Name: "bots", Schema: Bot{}, Opts: cli.BackupOpts{
+		IndexCols:    []string{"bot_id", "staff_bot", "cross_add", "api_token", "lower(vanity)"},
+		Transforms:   botTransforms,
+		RowTransform: botRowTransform,
+		// Read by the ExtraLinks transform and botRowTransform
+		KnownKeys: []string{"website", "support", "github", "donate", "certified", "claimed"},
+		// Stored answers (such as client IDs) are keyed by bot ID
+		SourceKey: "botID",
+	}},
+	{Name: "claims", Schema: Claims{}, Opts: cli.BackupOpts{
+		RenameTo: "reports",
+		Comment:  "Bot claims by staff, migrated from the claims collection",
+	}},
+	{Name: "announcements", Schema: Announcements{}, Opts: cli.BackupOpts{
+		Transforms: announcementTransforms,
+	}},
+	{Name: "votes", Schema: Votes{}, Opts: cli.BackupOpts{
+		IgnoreFKError: true,
+	}},
+	{Name: "packages", Schema: Packs{}, Opts: cli.BackupOpts{
+		IgnoreFKError: true,
+		RenameTo:      "packs",
+		Transforms:    packTransforms,
+	}},
+	{Name: "reviews", Schema: Reviews{}, Opts: cli.BackupOpts{
+		IgnoreFKError: true,
+		Transforms:    reviewTransforms,
+	}},
+	{Name: "tickets2", Schema: Tickets{}, Opts: cli.BackupOpts{
+		IgnoreFKError: true,
+		RenameTo:      "tickets",
+		Comment:       "Support tickets, migrated from the tickets2 collection",
+		Transforms:    ticketTransforms,
+	}},
+	{Name: "rpc_requests", Schema: RPCRequests{}, Opts: cli.BackupOpts{}},
+	{Name: "poppypaw", Schema: Poppypaw{}, Opts: cli.BackupOpts{}},
+	{Name: "silverpelt", Schema: Silverpelt{}, Opts: cli.BackupOpts{}},
+	{Name: "alerts", Schema: Alerts{}, Opts: cli.BackupOpts{}},
+	{Name: "action_logs", Schema: ActionLog{}, Opts: cli.BackupOpts{}},
+	{Name: "onboard_data", Schema: OnboardData{}, Opts: cli.BackupOpts{}},
+	{Name: "pack_votes", Schema: PackVotes{}, Opts: cli.BackupOpts{}},
+	{Name: "blogs", Schema: Blog{}, Opts: cli.BackupOpts{}},
+}
+
 func main() {
 	// Place all schemas to be used in the tool here
 
@@ -515,6 +424,8 @@ func main() {
 		SchemaOpts: cli.SchemaOpts{
 			TableName: "infinity",
 		},
+		// Used by the profile command
+		Schemas: cli.TableSchemas(tables),
 		// Required
 		LoadSource: func(name string) (cli.Source, error) {
 			sess, err := discordgo.New("Bot " + os.Getenv("DISCORD_TOKEN"))
@@ -580,60 +491,9 @@ func main() {
 				panic(err)
 			}
 
-			cli.BackupTool(source, "users", User{}, cli.BackupOpts{
-				IgnoreFKError:     true,
-				IgnoreUniqueError: true,
-				Transforms:        userTransforms,
-			})
-
-			cli.BackupTool(source, "apps", Apps{}, cli.BackupOpts{})
-
-			cli.BackupTool(source, "bots", Bot{}, cli.BackupOpts{
-				IndexCols:  []string{"bot_id", "staff_bot", "cross_add", "api_token", "lower(vanity)"},
-				Transforms: botTransforms,
-			})
-			cli.BackupTool(source, "claims", Claims{}, cli.BackupOpts{
-				RenameTo: "reports",
-			})
-			cli.BackupTool(source, "announcements", Announcements{}, cli.BackupOpts{
-				Transforms: announcementTransforms,
-			})
-			cli.BackupTool(source, "votes", Votes{}, cli.BackupOpts{
-				IgnoreFKError: true,
-			})
-			cli.BackupTool(source, "packages", Packs{}, cli.BackupOpts{
-				IgnoreFKError: true,
-				RenameTo:      "packs",
-				Transforms:    packTransforms,
-			})
-			cli.BackupTool(source, "reviews", Reviews{}, cli.BackupOpts{
-				IgnoreFKError: true,
-				Transforms:    reviewTransforms,
-			})
-			cli.BackupTool(source, "tickets2", Tickets{}, cli.BackupOpts{
-				IgnoreFKError: true,
-				RenameTo:      "tickets",
-			})
-
-			cli.BackupTool(source, "rpc_requests", RPCRequests{}, cli.BackupOpts{})
-
-			cli.BackupTool(source, "poppypaw", Poppypaw{}, cli.BackupOpts{})
-
-			cli.BackupTool(source, "silverpelt", Silverpelt{}, cli.BackupOpts{})
-
-			cli.BackupTool(source, "alerts", Alerts{}, cli.BackupOpts{})
-
-			cli.BackupTool(source, "action_logs", ActionLog{}, cli.BackupOpts{})
-
-			cli.BackupTool(source, "onboard_data", OnboardData{}, cli.BackupOpts{})
-
-			cli.BackupTool(source, "pack_votes", PackVotes{}, cli.BackupOpts{})
-
-			cli.BackupTool(source, "blogs", Blog{}, cli.BackupOpts{})
+			cli.BackupTables(source, tables)
 
 			migrations.Migrate(context.Background(), cli.Pool)
-
-			cli.Pool.Exec(context.Background(), "DELETE FROM bots WHERE bot_id = 'SKIP' OR client_id = 'SKIP'")
 		},
 	})
 }
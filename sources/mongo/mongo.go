@@ -103,12 +103,109 @@ func (m MongoSource) GetCount(entity string) (int64, error) {
 }
 
 // Special mongo specific types
+//
+// Documents and arrays are converted recursively so they can be stored as jsonb
 func (m MongoSource) ExtParse(res any) (any, error) {
-	var result any
-	if resCast, ok := res.(primitive.DateTime); ok {
-		result = time.UnixMilli(resCast.Time().UnixMilli())
-	} else {
-		return result, errors.New("no external representation for type")
+	switch resCast := res.(type) {
+	case primitive.DateTime:
+		return time.UnixMilli(resCast.Time().UnixMilli()), nil
+	case primitive.ObjectID:
+		return resCast.Hex(), nil
+	case primitive.Decimal128:
+		return resCast.String(), nil
+	case primitive.D:
+		return m.ExtParse(resCast.Map())
+	case primitive.M:
+		doc := make(map[string]any, len(resCast))
+		for k, v := range resCast {
+			doc[k] = m.extParseOrKeep(v)
+		}
+		return doc, nil
+	case primitive.A:
+		arr := make([]any, len(resCast))
+		for i, v := range resCast {
+			arr[i] = m.extParseOrKeep(v)
+		}
+		return arr, nil
 	}
-	return result, nil
+
+	return nil, errors.New("no external representation for type")
+}
+
+func (m MongoSource) extParseOrKeep(res any) any {
+	result, err := m.ExtParse(res)
+
+	if err != nil {
+		return res
+	}
+
+	return result
+}
+
+// Calls fn for each record of a entity, decoding one record at a time
+func (m MongoSource) StreamRecords(entity string, fn func(record map[string]any) error) error {
+	if slices.Contains(m.IgnoreEntities, entity) {
+		return nil
+	}
+
+	if !m.connected {
+		return errors.New("not connected")
+	}
+
+	cur, err := m.Database.Collection(entity).Find(ctx, bson.M{})
+
+	if err != nil {
+		return err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var mongoEntity bson.M
+
+		if err := cur.Decode(&mongoEntity); err != nil {
+			return err
+		}
+
+		if err := fn(mongoEntity); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+// Returns up to n random records of a entity
+func (m MongoSource) SampleRecords(entity string, n int) ([]map[string]any, error) {
+	if slices.Contains(m.IgnoreEntities, entity) {
+		return []map[string]any{}, nil
+	}
+
+	if !m.connected {
+		return nil, errors.New("not connected")
+	}
+
+	var record []map[string]any
+	cur, err := m.Database.Collection(entity).Aggregate(ctx, bson.A{bson.M{"$sample": bson.M{"size": n}}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var mongoEntity bson.M
+		err = cur.Decode(&mongoEntity)
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, mongoEntity)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return record, nil
 }
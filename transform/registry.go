@@ -0,0 +1,124 @@
+package transform
+
+import (
+	"errors"
+	"hepatitis-antiviral/cli"
+	"strconv"
+)
+
+// Registers the transforms of this package so mapping files can use them by name
+func init() {
+	cli.RegisterTransform("to_list", ToList)
+	cli.RegisterTransform("uuid", UUID)
+	cli.RegisterTransform("uuid_default", UUIDDefault)
+	cli.RegisterTransform("trim", Trim)
+	cli.RegisterTransform("lower", Lower)
+	cli.RegisterTransform("upper", Upper)
+	cli.RegisterTransform("strip_non_ascii", StripNonASCII)
+	cli.RegisterTransform("normalize_whitespace", NormalizeWhitespace)
+	cli.RegisterTransform("url", URL(DefaultURLRules))
+	cli.RegisterTransform("invite_url", URL(InviteURLRules))
+
+	cli.RegisterTransformFactory("regex_replace", func(args ...string) (cli.TransformFunc, error) {
+		if len(args) != 2 {
+			return nil, errors.New("regex_replace takes a pattern and a replacement")
+		}
+
+		return RegexReplace(args[0], args[1]), nil
+	})
+
+	cli.RegisterTransformFactory("truncate", func(args ...string) (cli.TransformFunc, error) {
+		if len(args) != 1 {
+			return nil, errors.New("truncate takes a length")
+		}
+
+		n, err := strconv.Atoi(args[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		return Truncate(n), nil
+	})
+
+	cli.RegisterTransformFactory("null_if", func(args ...string) (cli.TransformFunc, error) {
+		values := make([]any, len(args))
+
+		for i, arg := range args {
+			values[i] = arg
+		}
+
+		return NullIf(values...), nil
+	})
+
+	cli.RegisterTransformFactory("null_if_fold", func(args ...string) (cli.TransformFunc, error) {
+		return NullIfFold(args...), nil
+	})
+
+	cli.RegisterTransformFactory("split", func(args ...string) (cli.TransformFunc, error) {
+		return Split(args...), nil
+	})
+
+	cli.RegisterTransformFactory("links", func(args ...string) (cli.TransformFunc, error) {
+		return Links(args...), nil
+	})
+
+	cli.RegisterTransformFactory("snowflake", func(args ...string) (cli.TransformFunc, error) {
+		var opts SnowflakeOpts
+
+		for _, arg := range args {
+			switch arg {
+			case "bigint":
+				opts.Bigint = true
+			case "strict":
+				opts.Strict = true
+			default:
+				return nil, errors.New("unknown snowflake option " + arg)
+			}
+		}
+
+		return Snowflake(opts), nil
+	})
+
+	cli.RegisterTransformFactory("snowflake_timestamp", func(args ...string) (cli.TransformFunc, error) {
+		if len(args) != 1 {
+			return nil, errors.New("snowflake_timestamp takes a src key")
+		}
+
+		return SnowflakeTimestamp(args[0]), nil
+	})
+
+	// Arguments are the table, column, strategy (numeric, random or reject) and max length
+	cli.RegisterTransformFactory("slug", func(args ...string) (cli.TransformFunc, error) {
+		if len(args) < 2 || len(args) > 4 {
+			return nil, errors.New("slug takes a table, a column and optionally a strategy and max length")
+		}
+
+		opts := SlugOpts{Table: args[0], Column: args[1]}
+
+		if len(args) > 2 {
+			switch args[2] {
+			case "numeric":
+				opts.Strategy = SlugNumeric
+			case "random":
+				opts.Strategy = SlugRandom
+			case "reject":
+				opts.Strategy = SlugReject
+			default:
+				return nil, errors.New("unknown slug strategy " + args[2])
+			}
+		}
+
+		if len(args) > 3 {
+			n, err := strconv.Atoi(args[3])
+
+			if err != nil {
+				return nil, err
+			}
+
+			opts.MaxLen = n
+		}
+
+		return Slug(opts), nil
+	})
+}
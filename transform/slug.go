@@ -0,0 +1,156 @@
+package transform
+
+import (
+	"hepatitis-antiviral/cli"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/infinitybotlist/eureka/crypto"
+)
+
+// How Slug resolves a slug that is already taken
+type SlugStrategy int
+
+const (
+	// Appends -2, -3 etc. until the slug is free
+	SlugNumeric SlugStrategy = iota
+	// Appends a random suffix
+	SlugRandom
+	// Fails the row
+	SlugReject
+)
+
+func (s SlugStrategy) String() string {
+	switch s {
+	case SlugNumeric:
+		return "numeric"
+	case SlugRandom:
+		return "random"
+	case SlugReject:
+		return "reject"
+	}
+
+	return "unknown"
+}
+
+type SlugOpts struct {
+	// Table and column the slug is stored in, used to check for collisions
+	Table  string
+	Column string
+	// How to resolve collisions
+	Strategy SlugStrategy
+	// Maximum length of the slug (including any suffix), no limit if 0
+	MaxLen int
+	// Keeps values as they are unless they collide, for columns other tables refer to by value
+	KeepExisting bool
+}
+
+// A slug which collided with an existing one
+type slugCollision struct {
+	Table     string `json:"table"`
+	Column    string `json:"column"`
+	Value     string `json:"value"`
+	Slug      string `json:"slug"`
+	Resolved  string `json:"resolved,omitempty"`
+	Strategy  string `json:"strategy"`
+	Iteration int    `json:"iteration"`
+}
+
+var slugSeparators = regexp.MustCompile("[^a-z0-9]+")
+
+// Returns the URL safe form of a string: lowercase ASCII letters and digits separated by dashes
+func Slugify(s string) string {
+	s = strings.ToLower(StripNonASCII(cli.TransformRow{CurrentValue: s}).(string))
+	return strings.Trim(slugSeparators.ReplaceAllString(s, "-"), "-")
+}
+
+// Defines a transform converting a string to a unique slug
+//
+// Collisions are checked against rows already in the table and slugs returned earlier in this run (which may
+// not be inserted yet), each collision is added to the run report. Null values are left as is
+func Slug(opts SlugOpts) cli.TransformFunc {
+	if opts.Table == "" || opts.Column == "" {
+		panic("Slug: Table and Column must be set")
+	}
+
+	// Slugs returned in this run
+	taken := make(map[string]bool)
+
+	free := func(tr cli.TransformRow, slug string) bool {
+		return !taken[slug] && !tr.Lookup.Exists(opts.Table, opts.Column, slug)
+	}
+
+	return func(tr cli.TransformRow) any {
+		s, ok := tr.CurrentValue.(string)
+
+		if !ok {
+			return tr.CurrentValue
+		}
+
+		slug := s
+
+		if !opts.KeepExisting {
+			slug = truncateSlug(Slugify(s), opts.MaxLen)
+		}
+
+		if slug == "" {
+			return cli.FailRow("cannot create a slug from " + strconv.Quote(s))
+		}
+
+		if free(tr, slug) {
+			taken[slug] = true
+			return slug
+		}
+
+		collision := slugCollision{
+			Table:     opts.Table,
+			Column:    opts.Column,
+			Value:     s,
+			Slug:      slug,
+			Strategy:  opts.Strategy.String(),
+			Iteration: tr.CurrentIteration,
+		}
+
+		var resolved string
+
+		switch opts.Strategy {
+		case SlugNumeric:
+			for n := 2; ; n++ {
+				suffix := "-" + strconv.Itoa(n)
+				resolved = truncateSlug(slug, opts.MaxLen-len(suffix)) + suffix
+
+				if free(tr, resolved) {
+					break
+				}
+			}
+		case SlugRandom:
+			for {
+				suffix := "-" + strings.ToLower(crypto.RandString(12))
+				resolved = truncateSlug(slug, opts.MaxLen-len(suffix)) + suffix
+
+				if free(tr, resolved) {
+					break
+				}
+			}
+		default:
+			cli.Report("slug_collisions", collision)
+			return cli.FailRow("slug " + slug + " is already taken")
+		}
+
+		collision.Resolved = resolved
+		cli.Report("slug_collisions", collision)
+
+		taken[resolved] = true
+		return resolved
+	}
+}
+
+// Cuts a slug to at most n characters (if n is positive), without leaving a trailing dash
+func truncateSlug(slug string, n int) string {
+	if n <= 0 || len(slug) <= n {
+		return slug
+	}
+
+	return strings.TrimRight(slug[:n], "-")
+}
@@ -0,0 +1,132 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"hepatitis-antiviral/cli"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discord epoch (the first second of 2015) in milliseconds, snowflake timestamps are relative to this
+const DiscordEpoch = 1420070400000
+
+type SnowflakeOpts struct {
+	// Return snowflakes as int64 (for bigint columns) instead of strings
+	Bigint bool
+	// Fail the row if a snowflake is invalid, invalid snowflakes are kept (and reported) otherwise
+	Strict bool
+}
+
+// An invalid or padded snowflake found by Snowflake
+type snowflakeReport struct {
+	Value     string `json:"value"`
+	Problem   string `json:"problem"`
+	Iteration int    `json:"iteration"`
+}
+
+// Returns the creation time of a snowflake
+func SnowflakeTime(id string) (time.Time, error) {
+	if id == "" || len(id) > 20 {
+		return time.Time{}, errors.New("not a snowflake")
+	}
+
+	n, err := strconv.ParseUint(id, 10, 64)
+
+	if err != nil {
+		return time.Time{}, errors.New("not a snowflake")
+	}
+
+	return time.UnixMilli(int64(n>>22) + DiscordEpoch).UTC(), nil
+}
+
+// Returns a snowflake from a source as a string, floats (such as IDs decoded from JSON) are formatted without an exponent
+func snowflakeString(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	return strings.TrimSpace(fmt.Sprint(v))
+}
+
+// Returns true if id is a valid snowflake: a number whose creation time is after the Discord epoch and not in the future
+func IsSnowflake(id string) bool {
+	t, err := SnowflakeTime(id)
+
+	if err != nil || len(id) < 17 {
+		return false
+	}
+
+	return t.After(time.UnixMilli(DiscordEpoch)) && t.Before(time.Now().Add(time.Hour))
+}
+
+// Defines a transform validating snowflakes, surrounding whitespace is removed
+//
+// Invalid, whitespace padded and lossy (rounded float) snowflakes are added to the run report
+func Snowflake(opts SnowflakeOpts) cli.TransformFunc {
+	return func(tr cli.TransformRow) any {
+		if tr.CurrentValue == nil {
+			return nil
+		}
+
+		id := snowflakeString(tr.CurrentValue)
+
+		// Floats (from JSON sources) above 2^53 were already rounded when decoded, so the ID cannot be trusted
+		if f, ok := tr.CurrentValue.(float64); ok && f > 1<<53 {
+			cli.NotifyMsg("warning", "Snowflake lost precision when decoded: "+strconv.Quote(id))
+			cli.Report("snowflakes", snowflakeReport{Value: id, Problem: "lossy", Iteration: tr.CurrentIteration})
+			return cli.FailRow("snowflake " + strconv.Quote(id) + " lost precision when decoded as a float")
+		}
+
+		if s, ok := tr.CurrentValue.(string); ok && s != id {
+			cli.Report("snowflakes", snowflakeReport{Value: s, Problem: "padded", Iteration: tr.CurrentIteration})
+		}
+
+		if !IsSnowflake(id) {
+			cli.NotifyMsg("warning", "Invalid snowflake: "+strconv.Quote(id))
+			cli.Report("snowflakes", snowflakeReport{Value: id, Problem: "invalid", Iteration: tr.CurrentIteration})
+
+			if opts.Strict {
+				return cli.FailRow("invalid snowflake " + strconv.Quote(id))
+			}
+
+			return id
+		}
+
+		if opts.Bigint {
+			// IsSnowflake rejects IDs past int64, as their timestamp is far in the future
+			n, _ := strconv.ParseInt(id, 10, 64)
+			return n
+		}
+
+		return id
+	}
+}
+
+// Defines a transform filling in a missing time from the creation time of the snowflake under srcKey
+//
+// For example, the creation time of a bot can be derived from its ID. Values which are set are left as is
+func SnowflakeTimestamp(srcKey string) cli.TransformFunc {
+	return func(tr cli.TransformRow) any {
+		if tr.CurrentValue != nil {
+			return tr.CurrentValue
+		}
+
+		id, ok := tr.CurrentRecord[srcKey]
+
+		if !ok || id == nil {
+			return nil
+		}
+
+		s := snowflakeString(id)
+
+		if !IsSnowflake(s) {
+			return nil
+		}
+
+		t, _ := SnowflakeTime(s)
+
+		return t
+	}
+}
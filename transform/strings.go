@@ -0,0 +1,175 @@
+package transform
+
+import (
+	"hepatitis-antiviral/cli"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Defines a transform running transforms in order, each getting the value returned by the previous one
+//
+// Same as cli.Chain, stops at the first transform returning a signal (such as cli.ErrSkipRow) and returns it
+func Chain(fs ...cli.TransformFunc) cli.TransformFunc {
+	return cli.Chain(fs...)
+}
+
+// Defines a transform applying f to string values (and to each string of a list), other values are left as is
+func StringTransform(f func(s string) any) cli.TransformFunc {
+	return func(tr cli.TransformRow) any {
+		switch val := tr.CurrentValue.(type) {
+		case string:
+			return f(val)
+		case []string:
+			list := make([]any, len(val))
+
+			for i, s := range val {
+				list[i] = f(s)
+			}
+
+			return list
+		case []any:
+			list := make([]any, len(val))
+
+			for i, elem := range val {
+				if s, ok := elem.(string); ok {
+					list[i] = f(s)
+				} else {
+					list[i] = elem
+				}
+			}
+
+			return list
+		}
+
+		return tr.CurrentValue
+	}
+}
+
+// Defines a transform removing leading and trailing whitespace
+var Trim = StringTransform(func(s string) any {
+	return strings.TrimSpace(s)
+})
+
+// Defines a transform converting strings to lowercase
+var Lower = StringTransform(func(s string) any {
+	return strings.ToLower(s)
+})
+
+// Defines a transform converting strings to uppercase
+var Upper = StringTransform(func(s string) any {
+	return strings.ToUpper(s)
+})
+
+// Defines a transform removing all non ASCII characters
+var StripNonASCII = StringTransform(func(s string) any {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return -1
+		}
+		return r
+	}, s)
+})
+
+// Defines a transform trimming strings and replacing runs of whitespace with a single space
+var NormalizeWhitespace = StringTransform(func(s string) any {
+	return strings.Join(strings.Fields(s), " ")
+})
+
+// Defines a transform replacing all matches of a regex, the pattern is compiled once (and panics if invalid)
+func RegexReplace(pattern string, repl string) cli.TransformFunc {
+	reg := regexp.MustCompile(pattern)
+
+	return StringTransform(func(s string) any {
+		return reg.ReplaceAllString(s, repl)
+	})
+}
+
+// Defines a transform cutting strings to at most n characters
+func Truncate(n int) cli.TransformFunc {
+	return StringTransform(func(s string) any {
+		if utf8.RuneCountInString(s) <= n {
+			return s
+		}
+
+		return string([]rune(s)[:n])
+	})
+}
+
+// Defines a transform returning null if the value equals one of values
+func NullIf(values ...any) cli.TransformFunc {
+	return func(tr cli.TransformRow) any {
+		for _, v := range values {
+			if tr.CurrentValue == v {
+				return nil
+			}
+		}
+
+		return tr.CurrentValue
+	}
+}
+
+// Same as NullIf, but compares strings case insensitively
+func NullIfFold(values ...string) cli.TransformFunc {
+	return func(tr cli.TransformRow) any {
+		s, ok := tr.CurrentValue.(string)
+
+		if !ok {
+			return tr.CurrentValue
+		}
+
+		for _, v := range values {
+			if strings.EqualFold(s, v) {
+				return nil
+			}
+		}
+
+		return s
+	}
+}
+
+// Defines a transform splitting a string on any of seps (defaults to a comma) into a list
+//
+// Parts are trimmed and empty parts are dropped, null becomes an empty list and lists are left as is
+func Split(seps ...string) cli.TransformFunc {
+	if len(seps) == 0 {
+		seps = []string{","}
+	}
+
+	return func(tr cli.TransformRow) any {
+		if tr.CurrentValue == nil {
+			return []string{}
+		}
+
+		s, ok := tr.CurrentValue.(string)
+
+		if !ok {
+			return tr.CurrentValue
+		}
+
+		parts := []string{s}
+
+		for _, sep := range seps {
+			var split []string
+
+			for _, part := range parts {
+				split = append(split, strings.Split(part, sep)...)
+			}
+
+			parts = split
+		}
+
+		list := []string{}
+
+		for _, part := range parts {
+			part = strings.TrimSpace(part)
+
+			if part != "" {
+				list = append(list, part)
+			}
+		}
+
+		return list
+	}
+}
@@ -0,0 +1,195 @@
+package transform
+
+import (
+	"hepatitis-antiviral/cli"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Rules used to normalize URLs
+type URLRules struct {
+	// Values (compared case insensitively) which mean there is no URL, such as "none"
+	NullValues []string
+	// Upgrade http:// URLs to https://
+	UpgradeHTTP bool
+	// Rewrite invites without a scheme (discord.gg/abc, discord.com/invite/abc, abc) to a https://discord.gg invite,
+	// ignoring any whitespace in them. Other URLs without a scheme are checked against TLDs
+	DiscordInvite bool
+	// Top level domains of URLs without a scheme which are made https:// URLs, other URLs without a scheme are dropped
+	TLDs []string
+	// Also accept any two letter (country code) top level domain
+	CountryTLDs bool
+}
+
+// Rules used by URL and Links when no other rules are given
+var DefaultURLRules = URLRules{
+	NullValues:  []string{"none"},
+	UpgradeHTTP: true,
+	TLDs:        []string{"com", "net", "org", "fun", "app", "dev", "xyz"},
+	CountryTLDs: true,
+}
+
+// Rules for links to a Discord server
+var InviteURLRules = URLRules{
+	NullValues:    []string{"none"},
+	UpgradeHTTP:   true,
+	DiscordInvite: true,
+	TLDs:          DefaultURLRules.TLDs,
+	CountryTLDs:   true,
+}
+
+// Rules used by Links per column name (lowercased), DefaultURLRules is used for other columns
+var LinkRules = map[string]URLRules{
+	"support": InviteURLRules,
+}
+
+// A URL which was changed or dropped by URL or Links
+type urlAudit struct {
+	Column    string `json:"column,omitempty"`
+	Original  string `json:"original"`
+	Result    string `json:"result,omitempty"`
+	Action    string `json:"action"`
+	Iteration int    `json:"iteration"`
+}
+
+// Normalizes a URL, returning the new URL (empty if it was dropped) and what was done to it (empty if nothing)
+func NormalizeURL(link string, rules URLRules) (string, string) {
+	link = strings.TrimSpace(link)
+
+	if link == "" {
+		return "", ""
+	}
+
+	for _, v := range rules.NullValues {
+		if strings.EqualFold(link, v) {
+			return "", "nulled"
+		}
+	}
+
+	if strings.HasPrefix(link, "https://") {
+		return link, ""
+	}
+
+	if strings.HasPrefix(link, "http://") {
+		if rules.UpgradeHTTP {
+			return strings.Replace(link, "http://", "https://", 1), "upgraded"
+		}
+
+		return link, ""
+	}
+
+	if rules.DiscordInvite {
+		// Invites are often pasted with stray whitespace (discord.gg/ abc)
+		link = strings.Join(strings.Fields(link), "")
+		invite := strings.TrimPrefix(link, "www.")
+
+		switch {
+		case strings.HasPrefix(invite, "discord.gg/"):
+			return "https://discord.gg/" + invite[11:], "rewritten"
+		case strings.HasPrefix(invite, "discord.com/invite/"):
+			return "https://discord.gg/" + invite[19:], "rewritten"
+		case !strings.ContainsAny(link, "./"):
+			// A bare invite code
+			return "https://discord.gg/" + link, "rewritten"
+		}
+
+		// Some other host, so not an invite and checked like any other URL below
+	}
+
+	// No scheme, but it may be safe still
+	host := strings.Split(link, "/")[0]
+	parts := strings.Split(host, ".")
+	tld := parts[len(parts)-1]
+
+	if len(parts) > 1 && !strings.Contains(host, " ") && ((rules.CountryTLDs && len(tld) == 2) || slices.Contains(rules.TLDs, tld)) {
+		return "https://" + link, "rewritten"
+	}
+
+	return "", "dropped"
+}
+
+// Records a changed or dropped URL in the run report
+func auditURL(column string, original string, result string, action string, iteration int) {
+	if action == "" {
+		return
+	}
+
+	if action == "dropped" {
+		cli.NotifyMsg("warning", "Removing invalid link: "+original)
+	}
+
+	cli.Report("url_audit", urlAudit{
+		Column:    column,
+		Original:  original,
+		Result:    result,
+		Action:    action,
+		Iteration: iteration,
+	})
+}
+
+// Defines a transform normalizing URLs using rules, URLs which are dropped become null
+//
+// Every URL which was changed or dropped is added to the run report
+func URL(rules URLRules) cli.TransformFunc {
+	return func(tr cli.TransformRow) any {
+		s, ok := tr.CurrentValue.(string)
+
+		if !ok {
+			return tr.CurrentValue
+		}
+
+		res, action := NormalizeURL(s, rules)
+
+		auditURL("", s, res, action, tr.CurrentIteration)
+
+		if res == "" {
+			return nil
+		}
+
+		return res
+	}
+}
+
+// A named link, stored as a jsonb array of these
+type Link struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Defines a transform building a list of links from columns of the record, the src key of each is the lowercased column name
+//
+// URLs are normalized using the LinkRules of the column (or DefaultURLRules), dropped URLs are left out
+func Links(columns ...string) cli.TransformFunc {
+	return func(tr cli.TransformRow) any {
+		links := []Link{}
+
+		for _, name := range columns {
+			col := strings.ToLower(name)
+
+			value, ok := tr.CurrentRecord[col].(string)
+
+			if !ok {
+				continue
+			}
+
+			rules, ok := LinkRules[col]
+
+			if !ok {
+				rules = DefaultURLRules
+			}
+
+			res, action := NormalizeURL(value, rules)
+
+			auditURL(col, value, res, action, tr.CurrentIteration)
+
+			if res == "" {
+				continue
+			}
+
+			links = append(links, Link{Name: name, Value: res})
+		}
+
+		return links
+	}
+}
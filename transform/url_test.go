@@ -0,0 +1,33 @@
+package transform
+
+import "testing"
+
+func TestNormalizeInviteURL(t *testing.T) {
+	tests := []struct {
+		link   string
+		want   string
+		action string
+	}{
+		{"https://discord.gg/abc", "https://discord.gg/abc", ""},
+		{"http://discord.gg/abc", "https://discord.gg/abc", "upgraded"},
+		{"abc", "https://discord.gg/abc", "rewritten"},
+		{"discord.gg/abc", "https://discord.gg/abc", "rewritten"},
+		{"www.discord.com/invite/abc", "https://discord.gg/abc", "rewritten"},
+		// Stray whitespace was kept in the baseline and must not drop the link
+		{"  discord.gg/ abc ", "https://discord.gg/abc", "rewritten"},
+		{"discord.gg/abc\n", "https://discord.gg/abc", "rewritten"},
+		{"support.example.com/help me", "https://support.example.com/helpme", "rewritten"},
+		{"example.com", "https://example.com", "rewritten"},
+		{"discord.com/channels/1", "https://discord.com/channels/1", "rewritten"},
+		{"not.a-url", "", "dropped"},
+		{"None", "", "nulled"},
+	}
+
+	for _, tt := range tests {
+		got, action := NormalizeURL(tt.link, InviteURLRules)
+
+		if got != tt.want || action != tt.action {
+			t.Errorf("NormalizeURL(%q) = %q, %q, want %q, %q", tt.link, got, action, tt.want, tt.action)
+		}
+	}
+}